@@ -0,0 +1,46 @@
+package alerts
+
+import (
+	"time"
+
+	"gpu-sniper/config"
+)
+
+// Deduper decides which matching checks notify under config.AlertDedupe
+// settings. The monitor keeps one for the session; backtests replay history
+// through their own.
+type Deduper struct {
+	settings config.AlertDedupe
+	last     map[string]lastAlert // By product key
+}
+
+// lastAlert is the latest notification of a run of matching checks
+type lastAlert struct {
+	rule string
+	at   time.Time
+}
+
+// NewDeduper returns a Deduper that has not alerted yet
+func NewDeduper(settings config.AlertDedupe) *Deduper {
+	return &Deduper{settings: settings, last: make(map[string]lastAlert)}
+}
+
+// Allow reports whether a check of the product at the given time where rule
+// matched should alert. A different rule than the last one always alerts.
+// key is config.Product.Key, or the product ID where that is all there is.
+func (d *Deduper) Allow(key, rule string, at time.Time) bool {
+	last, ok := d.last[key]
+	if ok && last.rule == rule {
+		if d.settings.Once || at.Sub(last.at) < d.settings.RepeatAfter.Duration {
+			return false
+		}
+	}
+	d.last[key] = lastAlert{rule: rule, at: at}
+	return true
+}
+
+// Reset ends the product's run of matching checks; call it for successful
+// checks where no rule matched
+func (d *Deduper) Reset(key string) {
+	delete(d.last, key)
+}
@@ -1,10 +1,12 @@
 package alerts
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/faiface/beep"
@@ -53,15 +55,50 @@ func OpenURL(url string) error {
 	return cmd.Start()
 }
 
+// Alert describes a product that was detected in stock
+type Alert struct {
+	Product  config.Product
+	Location string  // Delivery ZIP code the product is available for, if set
+	Price    float64 // Buy-box price, 0 if unknown
+	Currency string  // ISO 4217 code of Price
+	CartURL  string  // Add-to-cart link from the source; built for Amazon, else the product page, when empty
+	Seller   string  // Who sells the offer, if known
+	Rule     string  // Name of the alert rule that matched
+}
+
 // TriggerPurchase performs all actions when a product is detected in stock
-func TriggerPurchase() {
-	// Construct the direct add-to-cart link using the productID and AssociateTag
-	addToCartURL := "https://www.amazon.com/gp/aws/cart/add-res.html?ASIN.1=" + config.ProductID + "&Quantity.1=1&AssociateTag=nisdisatc-20"
+func TriggerPurchase(alert Alert) {
+	product := alert.Product
+
+	// Construct the direct add-to-cart link on the product's marketplace.
+	// Other retailers without a link from their source get the product page.
+	addToCartURL := alert.CartURL
+	if addToCartURL == "" && product.Retailer == config.RetailerAmazon {
+		market := product.Market()
+		addToCartURL = "https://" + market.Host() + "/gp/aws/cart/add-res.html?ASIN.1=" + product.ID + "&Quantity.1=1"
+		if market.AssociateTag != "" {
+			addToCartURL += "&AssociateTag=" + market.AssociateTag
+		}
+	} else if addToCartURL == "" {
+		addToCartURL = product.URL
+	}
 
 	// Immediately display the alert and URL
-	alertMsg := color.New(color.FgHiGreen, color.Bold).Sprintf("🚨 ALERT: %s IS IN STOCK! 🚨", config.TargetGPU)
+	alertMsg := color.New(color.FgHiGreen, color.Bold).Sprintf("🚨 ALERT: %s IS IN STOCK! 🚨", product.DisplayName())
 	addToCartMsg := color.New(color.FgHiYellow, color.Bold).Sprintf("Direct Add-to-Cart: %s", addToCartURL)
 	fmt.Println("\n" + alertMsg)
+	if alert.Price > 0 {
+		fmt.Println(color.New(color.FgHiGreen).Sprintf("Price: %s", ui.FormatPrice(alert.Price, alert.Currency)))
+	}
+	if alert.Seller != "" {
+		fmt.Println(color.New(color.FgHiGreen).Sprintf("Sold by: %s", alert.Seller))
+	}
+	if alert.Rule != "" {
+		fmt.Println(color.New(color.FgHiGreen).Sprintf("Rule: %s", alert.Rule))
+	}
+	if alert.Location != "" {
+		fmt.Println(color.New(color.FgHiGreen).Sprintf("Delivery to: %s", alert.Location))
+	}
 	fmt.Println(addToCartMsg + "\n")
 
 	// Automatically open the URL in the default browser
@@ -69,16 +106,72 @@ func TriggerPurchase() {
 		ui.LogError("Failed to open add-to-cart link: %v", err)
 	}
 
-	// Play sound alerts in a goroutine
-	go func() {
-		const soundFile = "beep.wav" // Ensure this file exists in your project directory
+	// Play sound alerts in the background
+	enqueue(product.DisplayName())
+}
+
+// Pending sound alerts, played one after another by a single worker
+var (
+	queueMu     sync.Mutex
+	queue       chan string
+	queueClosed bool
+	queueDone   = make(chan struct{})
+)
+
+// enqueue schedules a sound alert, starting the worker on first use
+func enqueue(name string) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if queueClosed {
+		ui.LogWarning("Notifications are shutting down, skipping sound alert for %s", name)
+		return
+	}
+	if queue == nil {
+		queue = make(chan string, 16)
+		go soundWorker(queue)
+	}
+	select {
+	case queue <- name:
+	default:
+		ui.LogWarning("Notification queue is full, skipping sound alert for %s", name)
+	}
+}
+
+func soundWorker(pending <-chan string) {
+	defer close(queueDone)
+	const soundFile = "beep.wav" // Ensure this file exists in your project directory
+	for range pending {
 		for i := 0; i < 3; i++ {
 			if err := PlaySound(soundFile); err != nil {
 				ui.LogError("Failed to play alert sound: %v", err)
 			}
 			time.Sleep(200 * time.Millisecond)
 		}
-	}()
+	}
+}
+
+// Drain stops accepting notifications and waits for the queued ones to
+// finish, giving up when ctx is done
+func Drain(ctx context.Context) error {
+	queueMu.Lock()
+	started := queue != nil
+	if !queueClosed {
+		queueClosed = true
+		if started {
+			close(queue)
+		}
+	}
+	queueMu.Unlock()
+
+	if !started {
+		return nil
+	}
+	select {
+	case <-queueDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("pending notifications were not delivered: %w", ctx.Err())
+	}
 }
 
 // InitializeSoundSystem prepares the audio system for alerts
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gpu-sniper/config"
+	"gpu-sniper/history"
+	"gpu-sniper/ui"
+)
+
+const analyzeUsage = "analyze [-json] [-product id] [history-file]"
+
+// analyzeRecentRestocks is how many of a product's restocks the text report lists
+const analyzeRecentRestocks = 10
+
+// runAnalyze reports when products were restocked according to the history,
+// to help choose polling schedules
+func runAnalyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	asJSON := flags.Bool("json", false, "")
+	productID := flags.String("product", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return usageError(analyzeUsage)
+	}
+	path := config.HistoryFile
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	}
+
+	entries, err := history.Load(path)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	if *productID != "" {
+		var selected []history.Entry
+		for _, entry := range entries {
+			if strings.EqualFold(entry.ProductID, *productID) {
+				selected = append(selected, entry)
+			}
+		}
+		entries = selected
+	}
+	stats := history.Analyze(entries, productZone)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			ui.LogError("%v", err)
+			return exitIncomplete
+		}
+		return exitOK
+	}
+	if len(stats) == 0 {
+		ui.LogWarning("No successful checks recorded in %s", path)
+		return exitOK
+	}
+	for i, s := range stats {
+		if i > 0 {
+			fmt.Println()
+		}
+		printStats(s)
+	}
+	return exitOK
+}
+
+// productZone returns the schedule time zone of a configured product, else
+// the local zone
+func productZone(id string) *time.Location {
+	for _, product := range config.Products {
+		if product.ID == id {
+			return product.Location()
+		}
+	}
+	return time.Local
+}
+
+// printStats prints the restock patterns of one product
+func printStats(s *history.Stats) {
+	const timeFormat = "2006-01-02 03:04 PM"
+	loc := productZone(s.ProductID)
+	name := s.Product
+	for _, product := range config.Products {
+		if product.ID == s.ProductID {
+			name = product.DisplayName()
+		}
+	}
+	if name == "" {
+		name = s.ProductID
+	}
+
+	config.HeaderColor.Printf("%s (%s)\n", name, s.ProductID)
+	fmt.Println(strings.Repeat("─", 50))
+	fmt.Printf("  checks:    %d from %s to %s\n", s.Checks, s.First.In(loc).Format(timeFormat), s.Last.In(loc).Format(timeFormat))
+	fmt.Printf("  restocks:  %d", len(s.Restocks))
+	if s.StartedInStock {
+		fmt.Printf(" (in stock at the first check, not counted)")
+	}
+	fmt.Println()
+	if len(s.Restocks) == 0 {
+		return
+	}
+
+	fmt.Printf("  in stock:  %s in total", seconds(s.InStock))
+	if s.MedianInStock > 0 {
+		fmt.Printf(", median %s per restock", seconds(s.MedianInStock))
+	}
+	fmt.Println()
+	if s.MedianBetween > 0 {
+		fmt.Printf("  between:   median %s between restocks\n", seconds(s.MedianBetween))
+	}
+	if s.MedianPrice > 0 {
+		fmt.Printf("  price:     %s to %s at restock, median %s\n",
+			ui.FormatPrice(s.MinPrice, s.PriceCurrency), ui.FormatPrice(s.MaxPrice, s.PriceCurrency), ui.FormatPrice(s.MedianPrice, s.PriceCurrency))
+	}
+
+	config.InfoColor.Printf("\n  Restocks by hour (%s)\n", s.TimeZone)
+	for hour, count := range s.ByHour {
+		if count > 0 {
+			fmt.Printf("    %02d:00  %s %d\n", hour, histogramBar(count, s.ByHour[:]), count)
+		}
+	}
+	config.InfoColor.Printf("\n  Restocks by day\n")
+	for i := range s.ByWeekday {
+		day := (i + 1) % 7 // Monday first
+		fmt.Printf("    %s    %s %d\n", time.Weekday(day).String()[:3], histogramBar(s.ByWeekday[day], s.ByWeekday[:]), s.ByWeekday[day])
+	}
+
+	config.InfoColor.Printf("\n  Recent restocks\n")
+	recent := s.Restocks
+	if len(recent) > analyzeRecentRestocks {
+		fmt.Printf("    (%d earlier)\n", len(recent)-analyzeRecentRestocks)
+		recent = recent[len(recent)-analyzeRecentRestocks:]
+	}
+	for _, restock := range recent {
+		fmt.Printf("    %s  ", restock.Start.In(loc).Format(timeFormat))
+		if restock.End != nil {
+			fmt.Printf("in stock %s", seconds(restock.Duration))
+		} else {
+			fmt.Printf("still in stock at the last check")
+		}
+		if restock.Price > 0 {
+			fmt.Printf(" at %s", ui.FormatPrice(restock.Price, restock.Currency))
+		}
+		fmt.Println()
+	}
+}
+
+// histogramBar draws count as a bar scaled to the largest of counts
+func histogramBar(count int, counts []int) string {
+	const width = 30
+	most := 0
+	for _, c := range counts {
+		most = max(most, c)
+	}
+	if most == 0 || count == 0 {
+		return ""
+	}
+	return strings.Repeat("█", max(1, count*width/most))
+}
+
+// seconds formats a number of seconds as a duration
+func seconds(s float64) string {
+	return (time.Duration(s) * time.Second).Round(time.Second).String()
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gpu-sniper/alerts"
+	"gpu-sniper/config"
+	"gpu-sniper/history"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/stock"
+	"gpu-sniper/ui"
+)
+
+const backtestUsage = "backtest [-rules candidate.json] [-snapshots dir] [-product id] [history-file]"
+
+// candidateConfig is the layout of a -rules file: the "alerts" and "dedupe"
+// keys of the configuration file
+type candidateConfig struct {
+	Alerts []config.AlertRule  `json:"alerts,omitempty"`
+	Dedupe *config.AlertDedupe `json:"dedupe,omitempty"`
+}
+
+// runBacktest replays recorded checks through alert rules and dedupe
+// settings and reports when alerts would have fired and which restocks
+// they would have missed
+func runBacktest(args []string) int {
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	rulesPath := flags.String("rules", "", "")
+	snapshotDir := flags.String("snapshots", "", "")
+	productID := flags.String("product", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return usageError(backtestUsage)
+	}
+	path := config.HistoryFile
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	}
+
+	entries, err := history.Load(path)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	bt := newBacktest(config.Dedupe)
+	if *rulesPath != "" {
+		if err := bt.loadCandidate(*rulesPath); err != nil {
+			ui.LogError("%s: %v", *rulesPath, err)
+			return exitStartup
+		}
+	}
+	if *snapshotDir != "" {
+		if bt.snapshots, err = indexSnapshots(*snapshotDir); err != nil {
+			ui.LogError("%v", err)
+			return exitStartup
+		}
+	}
+
+	bt.replayAll(entries, *productID)
+	if len(bt.order) == 0 {
+		ui.LogWarning("No successful checks recorded in %s", path)
+		return exitOK
+	}
+	bt.report(path, *rulesPath)
+	return exitOK
+}
+
+// backtest is the state of one replay of the history
+type backtest struct {
+	candidate      []config.AlertRule // Replace every product's rules if set
+	dedupe         *alerts.Deduper
+	dedupeSettings config.AlertDedupe
+	snapshots      map[string]string // Snapshot paths by stock.SnapshotKey
+
+	products map[string]*productBacktest
+	order    []string // Product IDs in order of their first check
+}
+
+// productBacktest is what the replay found for one product
+type productBacktest struct {
+	product    config.Product
+	checks     int
+	alerts     []backtestAlert
+	suppressed int // Matching checks that dedupe silenced
+	recorded   int // Alerts the monitor raised at the time
+	restocks   []*restock
+	tracker    history.Tracker
+	replayed   int // Checks decided again from a snapshot
+	changed    int // Replayed checks whose availability differs from the record
+	failed     int // Snapshots that could not be replayed
+}
+
+type backtestAlert struct {
+	at       time.Time
+	rule     string
+	price    float64
+	currency string
+}
+
+// restock is a run of checks that found the product in stock after one that
+// found it out of stock, as history.Tracker detects it
+type restock struct {
+	start, end time.Time // end is zero while the product stays in stock
+	matched    bool      // Whether any check matched a rule
+	alerted    bool      // Whether any check alerted
+}
+
+// newBacktest returns a backtest that replays under the given dedupe settings
+func newBacktest(dedupe config.AlertDedupe) *backtest {
+	return &backtest{dedupe: alerts.NewDeduper(dedupe), dedupeSettings: dedupe}
+}
+
+// replayAll replays entries in time order, only those of productID if set
+func (bt *backtest) replayAll(entries []history.Entry, productID string) {
+	sorted := make([]history.Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	for _, entry := range sorted {
+		if productID == "" || strings.EqualFold(entry.ProductID, productID) {
+			bt.replay(entry)
+		}
+	}
+}
+
+// loadCandidate reads the rules and dedupe settings to try from a file
+func (bt *backtest) loadCandidate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var candidate candidateConfig
+	if err := json.Unmarshal(data, &candidate); err != nil {
+		return err
+	}
+	if len(candidate.Alerts) == 0 && candidate.Dedupe == nil {
+		return errors.New("no alerts or dedupe settings to try")
+	}
+	if err := config.CompileAlertRules(candidate.Alerts); err != nil {
+		return err
+	}
+	bt.candidate = candidate.Alerts
+	if candidate.Dedupe != nil {
+		if err := candidate.Dedupe.Validate(); err != nil {
+			return err
+		}
+		bt.dedupeSettings = *candidate.Dedupe
+		bt.dedupe = alerts.NewDeduper(bt.dedupeSettings)
+	}
+	return nil
+}
+
+// indexSnapshots finds the pages saved by stock.DebugSaveHTML in dir
+func indexSnapshots(dir string) (map[string]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string]string)
+	for _, file := range files {
+		if id, at, ok := stock.ParseSnapshotName(file.Name()); ok {
+			snapshots[stock.SnapshotKey(id, at)] = filepath.Join(dir, file.Name())
+		}
+	}
+	return snapshots, nil
+}
+
+// productFor returns the configured product an entry belongs to, or a bare
+// one for products that are no longer configured
+func productFor(entry history.Entry) (config.Product, bool) {
+	for _, product := range config.Products {
+		if product.ID == entry.ProductID {
+			return product, true
+		}
+	}
+	return config.Product{ID: entry.ProductID, Name: entry.Product}, false
+}
+
+// entryResult rebuilds the result of a successful check from its record
+func entryResult(entry history.Entry) stock.Result {
+	return stock.Result{
+		ProductID: entry.ProductID,
+		InStock:   entry.InStock,
+		Indicator: entry.Indicator,
+		CheckedAt: entry.Time,
+		Outcome:   stock.OutcomeSuccess,
+		Location:  entry.Location,
+		Price:     entry.Price,
+		Currency:  entry.Currency,
+		Seller:    entry.Seller,
+		Condition: entry.Condition,
+	}
+}
+
+// replay runs one recorded check through the rules and dedupe
+func (bt *backtest) replay(entry history.Entry) {
+	if entry.Outcome != stock.OutcomeSuccess.String() {
+		return
+	}
+	product, configured := productFor(entry)
+	if bt.products == nil {
+		bt.products = make(map[string]*productBacktest)
+	}
+	pb, ok := bt.products[product.ID]
+	if !ok {
+		pb = &productBacktest{product: product}
+		bt.products[product.ID] = pb
+		bt.order = append(bt.order, product.ID)
+	}
+	pb.checks++
+	if entry.Alerted {
+		pb.recorded++
+	}
+
+	result := entryResult(entry)
+	if path, ok := bt.snapshots[stock.SnapshotKey(entry.ProductID, entry.Time)]; ok && configured {
+		if replayed, err := replaySnapshot(product, path, entry.Time); err != nil || replayed.Outcome != stock.OutcomeSuccess {
+			pb.failed++
+		} else {
+			pb.replayed++
+			if replayed.InStock != entry.InStock {
+				pb.changed++
+			}
+			replayed.Location = entry.Location
+			result = replayed
+		}
+	}
+
+	var open *restock
+	if n := len(pb.restocks); n > 0 && pb.restocks[n-1].end.IsZero() {
+		open = pb.restocks[n-1]
+	}
+	switch started, ended := pb.tracker.Observe(result.InStock); {
+	case started:
+		open = &restock{start: entry.Time}
+		pb.restocks = append(pb.restocks, open)
+	case ended:
+		open.end = entry.Time
+		open = nil
+	}
+
+	rules := product.AlertRules()
+	if len(bt.candidate) > 0 {
+		rules = bt.candidate
+	}
+	facts := result.Facts(product)
+	for _, rule := range rules {
+		if !rule.Matches(facts) {
+			continue
+		}
+		if open != nil {
+			open.matched = true
+		}
+		if !bt.dedupe.Allow(product.ID, rule.Name, entry.Time) {
+			pb.suppressed++
+			return
+		}
+		if open != nil {
+			open.alerted = true
+		}
+		pb.alerts = append(pb.alerts, backtestAlert{at: entry.Time, rule: rule.Name, price: result.Price, currency: result.Currency})
+		return
+	}
+	bt.dedupe.Reset(product.ID)
+}
+
+// replaySnapshot decides availability again from a saved page
+func replaySnapshot(product config.Product, path string, fetchedAt time.Time) (stock.Result, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return stock.Result{}, err
+	}
+	page := httpClient.NewPage(product.URL, 200, nil, body)
+	page.FetchedAt = fetchedAt
+
+	ui.Quiet = true
+	defer func() { ui.Quiet = false }()
+	return stock.ReplaySnapshot(product, page)
+}
+
+// report prints the alerts and missed restocks of every product
+func (bt *backtest) report(path, rulesPath string) {
+	const timeFormat = "2006-01-02 03:04 PM"
+	var checks, fired, recorded, suppressed, restocks, missed int
+	var first, last time.Time
+	for _, id := range bt.order {
+		pb := bt.products[id]
+		checks += pb.checks
+		fired += len(pb.alerts)
+		recorded += pb.recorded
+		suppressed += pb.suppressed
+		restocks += len(pb.restocks)
+	}
+
+	config.HeaderColor.Printf("Backtest of %d check(s) in %s\n", checks, path)
+	fmt.Println(strings.Repeat("─", 50))
+	if rulesPath != "" && len(bt.candidate) > 0 {
+		names := make([]string, len(bt.candidate))
+		for i, rule := range bt.candidate {
+			names[i] = rule.Name
+		}
+		fmt.Printf("  rules:     %s from %s\n", strings.Join(names, ", "), rulesPath)
+	} else {
+		fmt.Printf("  rules:     as configured\n")
+	}
+	fmt.Printf("  dedupe:    %s\n", bt.dedupeSettings)
+	if bt.snapshots != nil {
+		fmt.Printf("  snapshots: %d found\n", len(bt.snapshots))
+	}
+
+	for _, id := range bt.order {
+		pb := bt.products[id]
+		config.InfoColor.Printf("\n%s (%s)\n", pb.product.DisplayName(), pb.product.ID)
+		fmt.Println(strings.Repeat("─", 50))
+		missed += pb.missed()
+		for _, line := range pb.reportLines() {
+			fmt.Printf("  %s  %s\n", line.at.Local().Format(timeFormat), line.text)
+		}
+		fmt.Printf("  %d check(s), %d alert(s) (%d raised at the time), %d suppressed, %d restock(s)\n",
+			pb.checks, len(pb.alerts), pb.recorded, pb.suppressed, len(pb.restocks))
+		if pb.replayed > 0 || pb.failed > 0 {
+			fmt.Printf("  %d snapshot(s) replayed, %d changed availability, %d could not be replayed\n", pb.replayed, pb.changed, pb.failed)
+		}
+		if len(pb.alerts) > 0 {
+			if at := pb.alerts[0].at; first.IsZero() || at.Before(first) {
+				first = at
+			}
+			if at := pb.alerts[len(pb.alerts)-1].at; at.After(last) {
+				last = at
+			}
+		}
+	}
+
+	fmt.Println()
+	config.HeaderColor.Printf("%d alert(s) would fire (%d raised at the time), %d suppressed, %d of %d restock(s) missed\n",
+		fired, recorded, suppressed, missed, restocks)
+	if fired > 0 {
+		fmt.Printf("  first alert %s, last alert %s\n", first.Local().Format(timeFormat), last.Local().Format(timeFormat))
+	}
+}
+
+// reportLine is a timestamped line of a product's report
+type reportLine struct {
+	at   time.Time
+	text string
+}
+
+// reportLines lists the product's alerts and missed restocks in time order
+func (pb *productBacktest) reportLines() []reportLine {
+	var lines []reportLine
+	for _, alert := range pb.alerts {
+		text := "alert   " + alert.rule
+		if alert.price > 0 {
+			text += " at " + ui.FormatPrice(alert.price, alert.currency)
+		}
+		lines = append(lines, reportLine{alert.at, text})
+	}
+	for _, r := range pb.restocks {
+		if r.alerted {
+			continue
+		}
+		reason := "no rule matched"
+		if r.matched {
+			reason = "suppressed by dedupe"
+		}
+		lines = append(lines, reportLine{r.start, fmt.Sprintf("missed  restock %s: %s", restockLength(r), reason)})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].at.Before(lines[j].at) })
+	return lines
+}
+
+// missed returns the number of restocks no alert covered
+func (pb *productBacktest) missed() int {
+	n := 0
+	for _, r := range pb.restocks {
+		if !r.alerted {
+			n++
+		}
+	}
+	return n
+}
+
+// restockLength describes how long a restock lasted
+func restockLength(r *restock) string {
+	if r.end.IsZero() {
+		return "still in stock at the last check"
+	}
+	return "lasting " + r.end.Sub(r.start).Round(time.Second).String()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gpu-sniper/config"
+	"gpu-sniper/history"
+)
+
+func TestBacktestReplay(t *testing.T) {
+	base := time.Date(2025, 1, 30, 14, 0, 0, 0, time.UTC)
+	check := func(minute int, inStock bool, price float64) history.Entry {
+		return history.Entry{
+			Time:      base.Add(time.Duration(minute) * time.Minute),
+			ProductID: "5090FE",
+			Product:   "RTX 5090 Founders Edition",
+			Outcome:   "success",
+			InStock:   inStock,
+			Price:     price,
+			Currency:  "USD",
+		}
+	}
+	// Out of order on purpose: the replay sorts by time
+	entries := []history.Entry{
+		check(70, true, 1999), // restock that alerts
+		check(0, true, 1999),  // started in stock, alerts but is no restock
+		check(10, true, 1999), // same run, suppressed
+		check(20, false, 1999),
+		check(30, true, 1999), // restock, rule matches but dedupe holds it back
+		check(40, false, 2500),
+		check(50, true, 2500), // restock no rule matches
+		check(60, false, 2500),
+		{Time: base.Add(65 * time.Minute), ProductID: "5090FE", Outcome: "blocked"},
+		check(80, true, 1999), // still the same run, suppressed
+		{Time: base.Add(90 * time.Minute), ProductID: "OTHER", Outcome: "success", InStock: true, Price: 1},
+	}
+	entries[0].Alerted = true
+
+	bt := newBacktest(config.AlertDedupe{Once: true})
+	bt.candidate = []config.AlertRule{config.MustCompileAlertRule("msrp", "price <= 2000")}
+	bt.replayAll(entries, "5090fe")
+
+	if len(bt.order) != 1 || bt.order[0] != "5090FE" {
+		t.Fatalf("replayed products = %v, want [5090FE]", bt.order)
+	}
+	pb := bt.products["5090FE"]
+	if pb.checks != 9 {
+		t.Errorf("checks = %d, want 9", pb.checks)
+	}
+	if pb.recorded != 1 {
+		t.Errorf("recorded = %d, want 1", pb.recorded)
+	}
+	if pb.suppressed != 4 {
+		t.Errorf("suppressed = %d, want 4", pb.suppressed)
+	}
+	if len(pb.restocks) != 3 {
+		t.Fatalf("restocks = %d, want 3 (the run in stock at the first check must not count)", len(pb.restocks))
+	}
+	if got := pb.missed(); got != 2 {
+		t.Errorf("missed = %d, want 2", got)
+	}
+
+	want := []reportLine{
+		{base, "alert   msrp at 1999.00 USD"},
+		{base.Add(30 * time.Minute), "missed  restock lasting 10m0s: suppressed by dedupe"},
+		{base.Add(50 * time.Minute), "missed  restock lasting 10m0s: no rule matched"},
+		{base.Add(70 * time.Minute), "alert   msrp at 1999.00 USD"},
+	}
+	lines := pb.reportLines()
+	if len(lines) != len(want) {
+		t.Fatalf("report lines = %v, want %v", lines, want)
+	}
+	for i := range want {
+		if !lines[i].at.Equal(want[i].at) || lines[i].text != want[i].text {
+			t.Errorf("line %d = %v %q, want %v %q", i, lines[i].at, lines[i].text, want[i].at, want[i].text)
+		}
+	}
+}
+
+func TestBacktestRepeatAfter(t *testing.T) {
+	base := time.Date(2025, 1, 30, 14, 0, 0, 0, time.UTC)
+	var entries []history.Entry
+	for minute := 0; minute <= 40; minute += 10 {
+		entries = append(entries, history.Entry{
+			Time:      base.Add(time.Duration(minute) * time.Minute),
+			ProductID: "5090FE",
+			Outcome:   "success",
+			InStock:   true,
+		})
+	}
+
+	bt := newBacktest(config.AlertDedupe{RepeatAfter: config.Duration{Duration: 25 * time.Minute}})
+	bt.candidate = []config.AlertRule{config.MustCompileAlertRule("any", "in_stock")}
+	bt.replayAll(entries, "")
+
+	pb := bt.products["5090FE"]
+	var fired []time.Duration
+	for _, alert := range pb.alerts {
+		fired = append(fired, alert.at.Sub(base))
+	}
+	if len(fired) != 2 || fired[0] != 0 || fired[1] != 30*time.Minute {
+		t.Errorf("alerts at %v, want [0s 30m0s]", fired)
+	}
+	if pb.suppressed != 3 {
+		t.Errorf("suppressed = %d, want 3", pb.suppressed)
+	}
+	if len(pb.restocks) != 0 {
+		t.Errorf("restocks = %d, want 0 for a run in stock from the first check", len(pb.restocks))
+	}
+}
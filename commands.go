@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/stock"
+	"gpu-sniper/ui"
+)
+
+// command is a subcommand run instead of the monitor
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"session", sessionUsage, runSession},
+	{"source", sourceUsage, runSource},
+	{"backtest", backtestUsage, runBacktest},
+	{"analyze", analyzeUsage, runAnalyze},
+}
+
+// runCommand runs the subcommand named by args[0]
+func runCommand(args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	ui.LogError("Unknown command %q", args[0])
+	printUsage()
+	return exitStartup
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: gpu-sniper [-config file] [command]\n\nWithout a command the monitor runs. Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// usageError reports a malformed command line for one command
+func usageError(usage string) int {
+	fmt.Fprintf(os.Stderr, "Usage: gpu-sniper [-config file] %s\n", usage)
+	return exitStartup
+}
+
+// openSession opens the retailer session with the cookies saved by the last run
+func openSession() (*httpClient.Session, error) {
+	return httpClient.Open(httpClient.SessionOptions{
+		CookieFile: config.CookieFile,
+		Passphrase: os.Getenv(config.CookiePassphraseEnv),
+	})
+}
+
+const sessionUsage = "session import <cookies.txt> | export <cookies.txt> | info"
+
+// runSession manages the saved retailer session
+func runSession(args []string) int {
+	if len(args) == 0 {
+		return usageError(sessionUsage)
+	}
+	switch {
+	case args[0] == "import" && len(args) == 2:
+		return importCookies(args[1])
+	case args[0] == "export" && len(args) == 2:
+		return exportCookies(args[1])
+	case args[0] == "info" && len(args) == 1:
+		return sessionInfo()
+	}
+	return usageError(sessionUsage)
+}
+
+// importCookies merges a browser-exported cookies.txt into the cookie file
+func importCookies(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	cookies, err := httpClient.ParseNetscapeCookies(file)
+	file.Close()
+	if err != nil {
+		ui.LogError("%s: %v", path, err)
+		return exitStartup
+	}
+
+	session, err := openSession()
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	imported, expired, rejected := session.ImportCookies(cookies)
+	if err := session.Close(); err != nil {
+		ui.LogError("%v", err)
+		return exitIncomplete
+	}
+	if rejected > 0 {
+		ui.LogWarning("Skipped %d cookie(s) with an invalid or public-suffix domain", rejected)
+	}
+	ui.LogSuccess("Imported %d cookie(s) into %s (%d expired, skipped)", imported, config.CookieFile, expired)
+	return exitOK
+}
+
+// exportCookies writes the saved session as cookies.txt, readable only by the owner
+func exportCookies(path string) int {
+	session, err := openSession()
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	cookies := session.Cookies()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	err = httpClient.WriteNetscapeCookies(file, cookies)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		ui.LogError("Failed to export cookies: %v", err)
+		return exitIncomplete
+	}
+	ui.LogSuccess("Exported %d cookie(s) to %s", len(cookies), path)
+	return exitOK
+}
+
+// sessionInfo lists the saved cookies and when they expire
+func sessionInfo() int {
+	session, err := openSession()
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	cookies := session.Cookies()
+	if len(cookies) == 0 {
+		ui.LogInfo("No cookies saved in %s", config.CookieFile)
+		return exitOK
+	}
+
+	config.HeaderColor.Printf("%d cookie(s) in %s\n", len(cookies), config.CookieFile)
+	domain := ""
+	for _, cookie := range cookies {
+		if cookie.Domain != domain {
+			domain = cookie.Domain
+			config.InfoColor.Printf("\n%s\n", domain)
+			fmt.Println(strings.Repeat("─", 50))
+		}
+		expires := "session"
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Local().Format("2006-01-02 03:04 PM")
+		}
+		var flags []string
+		if !cookie.HostOnly {
+			flags = append(flags, "subdomains")
+		}
+		if cookie.Secure {
+			flags = append(flags, "secure")
+		}
+		if cookie.HttpOnly {
+			flags = append(flags, "httponly")
+		}
+		fmt.Printf("  %-28s %-20s expires %s", cookie.Name, cookie.Path, expires)
+		if len(flags) > 0 {
+			fmt.Printf(" (%s)", strings.Join(flags, ", "))
+		}
+		fmt.Println()
+	}
+	return exitOK
+}
+
+const sourceUsage = "source test <name> <saved-response> [product-id]"
+
+// runSource works with the sources defined in the configuration file
+func runSource(args []string) int {
+	if len(args) < 3 || len(args) > 4 || args[0] != "test" {
+		return usageError(sourceUsage)
+	}
+	id := ""
+	if len(args) == 4 {
+		id = args[3]
+	}
+	return testSource(args[1], args[2], id)
+}
+
+// testSource runs a source's rules against a saved response and shows what
+// each rule extracted
+func testSource(name, path, id string) int {
+	def, ok := config.CustomSources[name]
+	if !ok {
+		ui.LogError("No source named %q in the configuration file", name)
+		return exitStartup
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+
+	page := httpClient.NewPage(path, 200, nil, body)
+	extraction, err := stock.ExtractCustom(name, page)
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitIncomplete
+	}
+
+	config.HeaderColor.Printf("Source %s against %s\n", name, path)
+	fmt.Println(strings.Repeat("─", 50))
+	if id != "" {
+		fmt.Printf("  request:      %s %s\n", def.Method, def.RequestURL(id))
+	}
+	switch {
+	case !extraction.Found:
+		fmt.Printf("  availability: no match -> not in stock\n")
+	case extraction.InStock:
+		fmt.Printf("  availability: %q -> in stock\n", extraction.Availability)
+	default:
+		fmt.Printf("  availability: %q -> not in stock\n", extraction.Availability)
+	}
+	if def.Price != nil {
+		if extraction.Price > 0 {
+			fmt.Printf("  price:        %q -> %s\n", extraction.PriceText, ui.FormatPrice(extraction.Price, def.Currency))
+		} else {
+			fmt.Printf("  price:        %q -> unreadable\n", extraction.PriceText)
+		}
+	}
+	if def.Title != nil {
+		fmt.Printf("  title:        %q\n", extraction.Title)
+	}
+	return exitOK
+}
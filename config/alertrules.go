@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"gpu-sniper/rules"
+)
+
+// AlertRule fires an alert when its condition holds for a check result
+type AlertRule struct {
+	Name string `json:"name"`
+	When string `json:"when"` // Condition over AlertFacts, e.g. `state == "in_stock" && price <= 1999`
+
+	expr *rules.Expr
+}
+
+// AlertFacts are the names alert conditions can use. Sources that don't
+// report a condition sell new items, so condition defaults to "new".
+var AlertFacts = rules.Schema{
+	"state":     rules.String, // "in_stock" or "out_of_stock"
+	"in_stock":  rules.Bool,
+	"price":     rules.Number, // 0 when the source reported no price
+	"currency":  rules.String,
+	"seller":    rules.String,
+	"condition": rules.String, // "new", "used", "refurbished", ...
+	"title":     rules.String,
+	"location":  rules.String,
+	"product":   rules.String, // Product ID
+	"retailer":  rules.String,
+}
+
+// DefaultAlertRules alert whenever a product is in stock; they apply to
+// products without rules of their own when the configuration file sets none
+var DefaultAlertRules = []AlertRule{MustCompileAlertRule("in stock", `state == "in_stock"`)}
+
+// AlertDedupe limits repeat alerts while the same rule keeps matching a
+// product. A check where no rule matches ends the run, so the next match
+// alerts again.
+type AlertDedupe struct {
+	RepeatAfter Duration `json:"repeat_after,omitempty"` // Alert again at most this often while the rule keeps matching; 0 alerts on every check
+	Once        bool     `json:"once,omitempty"`         // Alert only once until the rule stops matching
+}
+
+// Dedupe is how repeat alerts are limited; by default every matching check alerts
+var Dedupe AlertDedupe
+
+// Validate rejects negative durations
+func (d AlertDedupe) Validate() error {
+	if d.RepeatAfter.Duration < 0 {
+		return errors.New("dedupe: repeat_after must not be negative")
+	}
+	return nil
+}
+
+// String describes the settings for reports
+func (d AlertDedupe) String() string {
+	switch {
+	case d.Once:
+		return "once until the rule stops matching"
+	case d.RepeatAfter.Duration > 0:
+		return fmt.Sprintf("at most every %v while the rule keeps matching", d.RepeatAfter.Duration)
+	}
+	return "every matching check"
+}
+
+// Compile checks the rule's condition against AlertFacts
+func (r *AlertRule) Compile() error {
+	if r.Name == "" {
+		return errors.New("alert rule needs a name")
+	}
+	expr, err := rules.Compile(r.When, AlertFacts)
+	if err != nil {
+		return fmt.Errorf("alert rule %q: %w", r.Name, err)
+	}
+	r.expr = expr
+	return nil
+}
+
+// MustCompileAlertRule returns a compiled rule and panics if it is invalid
+func MustCompileAlertRule(name, when string) AlertRule {
+	rule := AlertRule{Name: name, When: when}
+	if err := rule.Compile(); err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// Matches reports whether the rule's condition holds for facts
+func (r AlertRule) Matches(facts rules.Vars) bool {
+	return r.expr != nil && r.expr.Eval(facts)
+}
+
+// AlertRules returns the rules that decide the product's alerts
+func (p Product) AlertRules() []AlertRule {
+	if len(p.Alerts) > 0 {
+		return p.Alerts
+	}
+	return DefaultAlertRules
+}
+
+// MatchAlertRule returns the first of the product's rules that holds for facts
+func (p Product) MatchAlertRule(facts rules.Vars) (AlertRule, bool) {
+	for _, rule := range p.AlertRules() {
+		if rule.Matches(facts) {
+			return rule, true
+		}
+	}
+	return AlertRule{}, false
+}
+
+// CompileAlertRules compiles a list of rules and rejects duplicate names
+func CompileAlertRules(list []AlertRule) error {
+	seen := make(map[string]bool)
+	for i := range list {
+		if err := list[i].Compile(); err != nil {
+			return err
+		}
+		if seen[list[i].Name] {
+			return fmt.Errorf("duplicate alert rule %q", list[i].Name)
+		}
+		seen[list[i].Name] = true
+	}
+	return nil
+}
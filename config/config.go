@@ -14,13 +14,47 @@ const (
 	TargetGPU           = "NVIDIA RTX 5090" // For display purposes; can be updated or removed as needed
 	DefaultPollingInterval = 30 * time.Second
 	ProgressWidth       = 40 // Width of the progress bar
+
+	MaintenanceBackoff = 10 * time.Minute // Wait after a 503 without Retry-After
+	MaxServerHintDelay = 30 * time.Minute // Upper bound for any server-requested delay
+	MaxCacheHintDelay  = 2 * time.Minute  // Upper bound for delays derived from cache headers
+
+	ShutdownTimeout = 5 * time.Second // How long shutdown waits for pending alerts
+
+	CookiePassphraseEnv = "GPU_SNIPER_COOKIE_PASSPHRASE" // Encrypts the cookie file when set
+	BestBuyAPIKeyEnv    = "BESTBUY_API_KEY"              // Best Buy Products API key
+	PAAPIAccessKeyEnv   = "PAAPI_ACCESS_KEY"             // Product Advertising API access key
+	PAAPISecretKeyEnv   = "PAAPI_SECRET_KEY"             // Product Advertising API secret key
+
+	PAAPIBatchSize   = 10              // Most ASINs one GetItems call accepts
+	PAAPIMinInterval = time.Second     // Spacing between PA-API calls (the default quota is 1 per second)
+	PAAPICacheTTL    = 5 * time.Second // How long a batched result answers checks of the other ASINs
 )
 
 // Application variables
 var (
-	PollingInterval = DefaultPollingInterval
 	CheckCount      = 0 // Counter for number of checks performed
+	SaveDebugHTML   = true // Save every fetched product page to debug_*.html
+	Parser          = ParserDOM // How product pages are parsed
+	HistoryFile     = "history.jsonl" // Every check result is appended here
+	CookieFile      = "cookies.json" // Session cookies persist here between runs
+	BestBuyBaseURL  = "https://api.bestbuy.com" // Products API root; point at a mock server for testing
+	BestBuyAPIKey   string // From BESTBUY_API_KEY or the configuration file
+	PAAPIPartnerTag string // Associates tag sent with PA-API requests
+	PAAPIEndpoint   string // Overrides https://webservices.<marketplace>/paapi5/getitems, e.g. for a local verifier
+	PAAPIAccessKey  string // From PAAPI_ACCESS_KEY
+	PAAPISecretKey  string // From PAAPI_SECRET_KEY
+	NVIDIAInventoryURL = "https://api.store.nvidia.com/partner/v1/feinventory" // Founders Edition inventory endpoint
 	LastCheckTime   time.Time // Time of the last check
+	ScriptTimeout   = 2 * time.Second // Wall-clock limit for one run of a product script
+	ScriptMaxSteps  uint64 = 10_000_000 // Starlark execution steps one run may take; 0 for no limit
+	MaxResponseBytes int64 = 8 << 20 // Largest decoded response body accepted
+)
+
+// Parser modes
+const (
+	ParserDOM    = "dom"    // Build the full DOM with goquery
+	ParserStream = "stream" // Tokenize and stop reading once availability is decided
 )
 
 // UI Colors for terminal output
@@ -67,8 +101,11 @@ var (
 		BackoffFactor:  1.5,
 	}
 
+	// CaptchaRetryConfig controls the cooling-down period after a CAPTCHA.
+	// Checks pause for InitialBackoff, escalating by BackoffFactor for every
+	// consecutive CAPTCHA up to MaxBackoff.
 	CaptchaRetryConfig = RetryConfig{
-		MaxRetries:     1,  // Don't retry immediately
+		MaxRetries:     0, // Never retry immediately
 		InitialBackoff: 5 * time.Minute,
 		MaxBackoff:     30 * time.Minute,
 		BackoffFactor:  2.0,
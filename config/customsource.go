@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RetailerCustom marks products checked by a source defined in the
+// configuration file
+const RetailerCustom = "custom"
+
+// CustomSource describes how to check a shop's products without writing Go:
+// which request to send and which rules pull the result out of the response
+type CustomSource struct {
+	URL          string            `json:"url"`                // Request URL; {id} is replaced with the product ID
+	Method       string            `json:"method,omitempty"`   // HTTP method (default GET)
+	Headers      map[string]string `json:"headers,omitempty"`  // Extra request headers
+	Body         string            `json:"body,omitempty"`     // Request body; {id} is replaced with the product ID
+	Link         string            `json:"link,omitempty"`     // Page opened by alerts; {id} is replaced (default URL)
+	Currency     string            `json:"currency,omitempty"` // ISO 4217 code of extracted prices (default USD)
+	Decimal      string            `json:"decimal,omitempty"`  // Decimal separator of extracted prices, "." or "," (default ".")
+	Availability ExtractRule       `json:"availability"`
+	Price        *ExtractRule      `json:"price,omitempty"`
+	Title        *ExtractRule      `json:"title,omitempty"`
+}
+
+// ExtractRule selects one value from a response with exactly one of a CSS
+// selector, an XPath expression or a JSONPath expression
+type ExtractRule struct {
+	CSS      string `json:"css,omitempty"`
+	XPath    string `json:"xpath,omitempty"`
+	JSONPath string `json:"jsonpath,omitempty"`
+	Attr     string `json:"attr,omitempty"`     // With css, read this attribute instead of the text
+	Regex    string `json:"regex,omitempty"`    // Keeps the first group (or the whole match) of the value
+	InStock  string `json:"in_stock,omitempty"` // Availability only: pattern an in-stock value matches
+}
+
+// CustomSources are the sources defined in the configuration file, by name
+var CustomSources = map[string]CustomSource{}
+
+// RequestURL returns the URL checked for the product with the given ID
+func (s CustomSource) RequestURL(id string) string {
+	return expandID(s.URL, id, url.PathEscape)
+}
+
+// RequestBody returns the request body sent for the product with the given ID
+func (s CustomSource) RequestBody(id string) string {
+	return expandID(s.Body, id, func(id string) string { return id })
+}
+
+// ProductURL returns the page alerts open for the product with the given ID
+func (s CustomSource) ProductURL(id string) string {
+	if s.Link == "" {
+		return s.RequestURL(id)
+	}
+	return expandID(s.Link, id, url.PathEscape)
+}
+
+// PriceFormat returns the marketplace whose decimal separator extracted
+// prices are read with
+func (s CustomSource) PriceFormat() Marketplace {
+	decimal := byte('.')
+	if s.Decimal != "" {
+		decimal = s.Decimal[0]
+	}
+	return Marketplace{Currency: s.Currency, Decimal: decimal}
+}
+
+func expandID(template, id string, escape func(string) string) string {
+	return strings.ReplaceAll(template, "{id}", escape(id))
+}
+
+// normalize fills in defaults and checks everything that doesn't depend on
+// the rule languages, which the stock package compiles
+func (s *CustomSource) normalize(name string) error {
+	if name == "" || name == SourcePage || name == SourcePAAPI {
+		return fmt.Errorf("source name %q is reserved", name)
+	}
+	if s.URL == "" {
+		return errors.New("missing url")
+	}
+	if u, err := url.Parse(s.RequestURL("0")); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %q", s.URL)
+	}
+	s.Method = strings.ToUpper(s.Method)
+	switch s.Method {
+	case "":
+		s.Method = http.MethodGet
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("unsupported method %q", s.Method)
+	}
+	if s.Currency == "" {
+		s.Currency = "USD"
+	}
+	s.Currency = strings.ToUpper(s.Currency)
+	switch s.Decimal {
+	case "":
+		s.Decimal = "."
+	case ".", ",":
+	default:
+		return fmt.Errorf("decimal must be \".\" or \",\", not %q", s.Decimal)
+	}
+
+	if err := s.Availability.validate(); err != nil {
+		return fmt.Errorf("availability: %w", err)
+	}
+	for field, rule := range map[string]*ExtractRule{"price": s.Price, "title": s.Title} {
+		if rule == nil {
+			continue
+		}
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		if rule.InStock != "" {
+			return fmt.Errorf("%s: in_stock only applies to availability", field)
+		}
+	}
+	return nil
+}
+
+func (r ExtractRule) validate() error {
+	set := 0
+	for _, expr := range []string{r.CSS, r.XPath, r.JSONPath} {
+		if expr != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("needs exactly one of css, xpath or jsonpath")
+	}
+	if r.Attr != "" && r.CSS == "" {
+		return errors.New("attr only applies to css rules")
+	}
+	for _, pattern := range []string{r.Regex, r.InStock} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,542 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ConfigFile is the optional JSON configuration file read at startup
+var ConfigFile = "gpu-sniper.json"
+
+// Product describes a product to monitor
+type Product struct {
+	ID       string    `json:"id,omitempty"`        // Retailer product identifier (ASIN), taken from URL if empty
+	Name     string    `json:"name,omitempty"`      // Display name
+	URL      string    `json:"url,omitempty"`       // Any link to the product page, derived from ID if empty
+	TimeZone string    `json:"time_zone,omitempty"` // IANA zone for schedules, defaults to the file's zone
+	Schedule *Schedule `json:"schedule,omitempty"`  // Polling schedule, defaults to the file's schedule
+	ZIP      string    `json:"zip,omitempty"`       // Delivery ZIP or postal code, defaults to the file's
+
+	// Amazon storefront domain (e.g. "amazon.de") used to build URL when it is empty
+	Marketplace string `json:"marketplace,omitempty"`
+	Retailer    string `json:"retailer,omitempty"` // Needed with a bare id for retailers other than Amazon
+	Locale      string `json:"locale,omitempty"`   // Store locale for NVIDIA products, e.g. "en-us"
+	Source      string `json:"source,omitempty"`   // "page" (default), "paapi" or the name of a source defined under "sources"
+	Script      string `json:"script,omitempty"`   // Starlark file that decides availability instead of the source's parser
+
+	// When to alert, first matching rule wins; defaults to the file's alerts,
+	// else to alerting whenever the product is in stock
+	Alerts []AlertRule `json:"alerts,omitempty"`
+}
+
+// normalize derives the retailer, canonical ID and canonical URL from
+// whichever of ID and URL was given
+func (p *Product) normalize() error {
+	// IDs of config-defined sources are used verbatim
+	if source, ok := CustomSources[p.Source]; ok {
+		p.ID = strings.TrimSpace(p.ID)
+		if p.ID == "" {
+			return errors.New("missing id")
+		}
+		p.Retailer = RetailerCustom
+		if p.URL == "" {
+			p.URL = source.ProductURL(p.ID)
+		}
+		return nil
+	}
+
+	p.ID = strings.ToUpper(strings.TrimSpace(p.ID))
+	if p.URL != "" {
+		ref, err := ParseProductURL(p.URL)
+		if err != nil {
+			return err
+		}
+		if p.Retailer != "" && p.Retailer != ref.Retailer {
+			return fmt.Errorf("retailer %s does not match url %s", p.Retailer, p.URL)
+		}
+		if p.ID != "" && !strings.EqualFold(p.ID, ref.ID) {
+			return fmt.Errorf("id %s does not match %s in url %s", p.ID, ref.ID, p.URL)
+		}
+		p.ID, p.Retailer, p.URL = ref.ID, ref.Retailer, ref.URL()
+		return nil
+	}
+
+	if p.ID == "" {
+		return errors.New("missing id or url")
+	}
+	switch p.Retailer {
+	case RetailerBestBuy:
+		if !ValidSKU(p.ID) {
+			return fmt.Errorf("id %q is not a valid Best Buy SKU (expected 7 or 8 digits)", p.ID)
+		}
+		p.URL = ProductRef{Retailer: RetailerBestBuy, Domain: "bestbuy.com", ID: p.ID}.URL()
+		return nil
+	case RetailerNewegg:
+		id, ok := NormalizeNeweggItem(p.ID)
+		if !ok {
+			return fmt.Errorf("id %q is not a valid Newegg item number (e.g. N82E16814126659)", p.ID)
+		}
+		p.ID = id
+		p.URL = ProductRef{Retailer: RetailerNewegg, Domain: "newegg.com", ID: p.ID}.URL()
+		return nil
+	case RetailerNVIDIA:
+		if !nvidiaSKUPattern.MatchString(p.ID) {
+			return fmt.Errorf("id %q is not a valid NVIDIA store SKU (e.g. NVGFT590)", p.ID)
+		}
+		p.Locale = strings.ToLower(p.Locale)
+		if p.Locale == "" {
+			p.Locale = "en-us"
+		}
+		p.URL = "https://marketplace.nvidia.com/" + p.Locale + "/consumer/graphics-cards/?sku=" + p.ID
+		return nil
+	case "", RetailerAmazon:
+	default:
+		return fmt.Errorf("unknown retailer %q", p.Retailer)
+	}
+	if !ValidASIN(p.ID) {
+		return fmt.Errorf("id %q: %w", p.ID, ErrInvalidASIN)
+	}
+	market := Marketplaces[0]
+	if p.Marketplace != "" {
+		m, ok := MarketplaceFor(p.Marketplace)
+		if !ok {
+			return fmt.Errorf("unknown marketplace %q; supported: %s", p.Marketplace, strings.Join(SupportedHosts(), ", "))
+		}
+		market = m
+	}
+	p.Retailer = RetailerAmazon
+	p.URL = ProductRef{Retailer: RetailerAmazon, Domain: market.Domain, ID: p.ID}.URL()
+	return nil
+}
+
+// Market returns the Amazon storefront the product is sold on
+func (p Product) Market() Marketplace {
+	if u, err := url.Parse(p.URL); err == nil {
+		if m, ok := MarketplaceFor(u.Host); ok {
+			return m
+		}
+	}
+	if m, ok := MarketplaceFor(p.Marketplace); ok {
+		return m
+	}
+	return Marketplaces[0]
+}
+
+// postalCodePattern accepts US ZIP codes and most international postal codes
+var postalCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{1,9}$`)
+
+// Location returns the time zone used for the product's schedule
+func (p Product) Location() *time.Location {
+	if p.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Key identifies the product's entry in the configuration. The same product
+// may be listed once per delivery location, so the ZIP is part of the key.
+func (p Product) Key() string {
+	if p.ZIP == "" {
+		return p.ID
+	}
+	return p.ID + "@" + p.ZIP
+}
+
+// DisplayName returns the name shown in logs and alerts
+func (p Product) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.ID
+}
+
+// Schedule defines when and how often a product is checked
+type Schedule struct {
+	Windows  []ScheduleWindow `json:"windows,omitempty"`   // First matching window wins
+	LaunchAt []LaunchEvent    `json:"launch_at,omitempty"` // One-off events, take precedence over windows
+}
+
+// Window modes
+const (
+	WindowNormal   = "normal"   // Check every Interval
+	WindowBurst    = "burst"    // Check every Interval, intended to be faster than usual
+	WindowBlackout = "blackout" // Don't check at all
+)
+
+// ScheduleWindow is a recurring daily time range with its own polling behavior
+type ScheduleWindow struct {
+	Name     string    `json:"name,omitempty"`
+	Days     Weekdays  `json:"days,omitempty"`     // Empty means every day
+	Start    ClockTime `json:"start"`              // Start of the window, e.g. "06:00"
+	End      ClockTime `json:"end"`                // End of the window (exclusive), may wrap past midnight
+	Mode     string    `json:"mode,omitempty"`     // normal, burst or blackout
+	Interval Duration  `json:"interval,omitempty"` // Interval while the window is active, default interval if empty
+	Jitter   Duration  `json:"jitter,omitempty"`   // Random extra delay of up to this much
+}
+
+// LaunchEvent is a one-off drop at a known time; checks run every Interval
+// from Before ahead of At until After past it
+type LaunchEvent struct {
+	Name     string   `json:"name,omitempty"`
+	At       string   `json:"at"`               // RFC 3339, or "2006-01-02 15:04" in the product's time zone
+	Before   Duration `json:"before,omitempty"` // How early to start bursting
+	After    Duration `json:"after,omitempty"`  // How long to keep bursting
+	Interval Duration `json:"interval"`         // Interval during the event
+}
+
+// Time returns the launch time interpreted in loc
+func (e LaunchEvent) Time(loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, e.At); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", e.At, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid launch time %q: use RFC 3339 or \"2006-01-02 15:04\"", e.At)
+	}
+	return t, nil
+}
+
+// DefaultSchedule checks less often late at night and is more cautious
+// during high traffic hours
+var DefaultSchedule = Schedule{
+	Windows: []ScheduleWindow{
+		{Name: "night", Start: 1 * 60, End: 6 * 60, Interval: Duration{2 * DefaultPollingInterval}},
+		{Name: "lunch peak", Start: 12 * 60, End: 15 * 60, Jitter: Duration{30 * time.Second}},
+		{Name: "evening peak", Start: 18 * 60, End: 22 * 60, Jitter: Duration{30 * time.Second}},
+	},
+}
+
+// Products lists the products to monitor
+var Products = []Product{
+	{ID: ProductID, Name: TargetGPU, URL: RetailerURL, Retailer: RetailerAmazon},
+}
+
+// UseProductURL replaces Products with the single product a pasted link
+// points to, e.g. from the -url flag
+func UseProductURL(rawURL string) error {
+	product := Product{URL: rawURL}
+	if err := product.normalize(); err != nil {
+		return err
+	}
+	if product.Retailer == RetailerBestBuy && BestBuyAPIKey == "" {
+		return fmt.Errorf("Best Buy products need a Products API key (set %s)", BestBuyAPIKeyEnv)
+	}
+	Products = []Product{product}
+	return nil
+}
+
+// fileConfig is the layout of the configuration file
+type fileConfig struct {
+	Parser      string `json:"parser,omitempty"`
+	HistoryFile string `json:"history_file,omitempty"`
+	ZIP         string `json:"zip,omitempty"`
+	PAAPI       *struct {
+		PartnerTag string `json:"partner_tag,omitempty"`
+		Endpoint   string `json:"endpoint,omitempty"`
+	} `json:"paapi,omitempty"`
+	NVIDIA *struct {
+		InventoryURL string `json:"inventory_url,omitempty"`
+	} `json:"nvidia,omitempty"`
+	BestBuy *struct {
+		BaseURL string `json:"base_url,omitempty"`
+		APIKey  string `json:"api_key,omitempty"`
+	} `json:"bestbuy,omitempty"`
+	Sources    map[string]CustomSource `json:"sources,omitempty"`
+	Alerts     []AlertRule             `json:"alerts,omitempty"`
+	Dedupe     *AlertDedupe            `json:"dedupe,omitempty"`
+	CookieFile string                  `json:"cookie_file,omitempty"`
+	TimeZone   string                  `json:"time_zone,omitempty"`
+	Schedule   *Schedule               `json:"schedule,omitempty"`
+	Products   []Product               `json:"products"`
+}
+
+// Load reads the configuration file at path and replaces Products and
+// DefaultSchedule. A missing file keeps the built-in defaults.
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file fileConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if len(file.Products) == 0 {
+		return fmt.Errorf("invalid config file %s: no products defined", path)
+	}
+	switch file.Parser {
+	case "", ParserDOM, ParserStream:
+	default:
+		return fmt.Errorf("invalid config file %s: unknown parser %q (use %q or %q)", path, file.Parser, ParserDOM, ParserStream)
+	}
+
+	if file.Schedule != nil {
+		if err := file.Schedule.validate(); err != nil {
+			return fmt.Errorf("invalid default schedule: %w", err)
+		}
+	}
+
+	if err := CompileAlertRules(file.Alerts); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if file.Dedupe != nil {
+		if err := file.Dedupe.Validate(); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	// Custom sources must be known before products refer to them
+	for name, source := range file.Sources {
+		if err := source.normalize(name); err != nil {
+			return fmt.Errorf("source %s: %w", name, err)
+		}
+		file.Sources[name] = source
+	}
+	CustomSources = file.Sources
+	if CustomSources == nil {
+		CustomSources = map[string]CustomSource{}
+	}
+
+	keys := make(map[string]int)
+	for i := range file.Products {
+		product := &file.Products[i]
+		if err := product.normalize(); err != nil {
+			return fmt.Errorf("product #%d: %w", i+1, err)
+		}
+		if err := CompileAlertRules(product.Alerts); err != nil {
+			return fmt.Errorf("product %s: %w", product.ID, err)
+		}
+		if product.TimeZone == "" {
+			product.TimeZone = file.TimeZone
+		}
+		if product.ZIP == "" {
+			product.ZIP = file.ZIP
+		}
+		product.ZIP = strings.TrimSpace(product.ZIP)
+		if product.ZIP != "" && !postalCodePattern.MatchString(product.ZIP) {
+			return fmt.Errorf("product %s: invalid zip %q", product.ID, product.ZIP)
+		}
+		if first, ok := keys[product.Key()]; ok {
+			return fmt.Errorf("product #%d: %s is already listed as product #%d; give each entry of a product its own zip", i+1, product.ID, first)
+		}
+		keys[product.Key()] = i + 1
+		if product.TimeZone != "" {
+			if _, err := time.LoadLocation(product.TimeZone); err != nil {
+				return fmt.Errorf("product %s: unknown time zone %q", product.ID, product.TimeZone)
+			}
+		}
+		if product.Schedule == nil {
+			product.Schedule = file.Schedule
+		}
+		if product.Schedule != nil {
+			if err := product.Schedule.validate(); err != nil {
+				return fmt.Errorf("product %s: %w", product.ID, err)
+			}
+			for _, event := range product.Schedule.LaunchAt {
+				if _, err := event.Time(product.Location()); err != nil {
+					return fmt.Errorf("product %s: %w", product.ID, err)
+				}
+			}
+		}
+	}
+
+	if file.BestBuy != nil {
+		if file.BestBuy.BaseURL != "" {
+			BestBuyBaseURL = strings.TrimRight(file.BestBuy.BaseURL, "/")
+		}
+		if file.BestBuy.APIKey != "" && BestBuyAPIKey == "" {
+			BestBuyAPIKey = file.BestBuy.APIKey
+		}
+	}
+	if file.NVIDIA != nil && file.NVIDIA.InventoryURL != "" {
+		NVIDIAInventoryURL = file.NVIDIA.InventoryURL
+	}
+	if file.PAAPI != nil {
+		if file.PAAPI.PartnerTag != "" {
+			PAAPIPartnerTag = file.PAAPI.PartnerTag
+		}
+		if file.PAAPI.Endpoint != "" {
+			PAAPIEndpoint = file.PAAPI.Endpoint
+		}
+	}
+	for _, product := range file.Products {
+		switch product.Source {
+		case "", SourcePage:
+		case SourcePAAPI:
+			if product.Retailer != RetailerAmazon {
+				return fmt.Errorf("product %s: the paapi source only checks Amazon products", product.ID)
+			}
+			if PAAPIPartnerTag == "" || PAAPIAccessKey == "" || PAAPISecretKey == "" {
+				return fmt.Errorf("product %s: the paapi source needs paapi.partner_tag, %s and %s", product.ID, PAAPIAccessKeyEnv, PAAPISecretKeyEnv)
+			}
+		default:
+			if product.Retailer != RetailerCustom {
+				return fmt.Errorf("product %s: unknown source %q (use %q, %q or a name under \"sources\")", product.ID, product.Source, SourcePage, SourcePAAPI)
+			}
+		}
+		if product.Retailer == RetailerBestBuy && BestBuyAPIKey == "" {
+			return fmt.Errorf("product %s: Best Buy products need a Products API key (set %s or bestbuy.api_key)", product.ID, BestBuyAPIKeyEnv)
+		}
+	}
+
+	Products = file.Products
+	if len(file.Alerts) > 0 {
+		DefaultAlertRules = file.Alerts
+	}
+	if file.Dedupe != nil {
+		Dedupe = *file.Dedupe
+	}
+	if file.Parser != "" {
+		Parser = file.Parser
+	}
+	if file.HistoryFile != "" {
+		HistoryFile = file.HistoryFile
+	}
+	if file.CookieFile != "" {
+		CookieFile = file.CookieFile
+	}
+	if file.Schedule != nil {
+		DefaultSchedule = *file.Schedule
+	}
+	return nil
+}
+
+func (s *Schedule) validate() error {
+	for i := range s.Windows {
+		window := &s.Windows[i]
+		switch window.Mode {
+		case "":
+			window.Mode = WindowNormal
+		case WindowNormal, WindowBurst, WindowBlackout:
+		default:
+			return fmt.Errorf("window %q: unknown mode %q", window.Name, window.Mode)
+		}
+		if window.Mode == WindowBurst && window.Interval.Duration <= 0 {
+			return fmt.Errorf("window %q: burst windows need an interval", window.Name)
+		}
+	}
+	for _, event := range s.LaunchAt {
+		if event.Interval.Duration <= 0 {
+			return fmt.Errorf("launch %q: missing interval", event.Name)
+		}
+	}
+	return nil
+}
+
+// Duration is a time.Duration read from strings like "30s" or "5m"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// ClockTime is a time of day in minutes since midnight, written as "15:04"
+type ClockTime int
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return fmt.Errorf("invalid time of day %q: use \"15:04\"", s)
+	}
+	*c = ClockTime(t.Hour()*60 + t.Minute())
+	return nil
+}
+
+// Weekdays is a set of days written as ["mon", "wed-fri"]
+type Weekdays []time.Weekday
+
+// Contains reports whether day is in the set; an empty set contains every day
+func (w Weekdays) Contains(day time.Weekday) bool {
+	if len(w) == 0 {
+		return true
+	}
+	for _, d := range w {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (w Weekdays) MarshalJSON() ([]byte, error) {
+	names := make([]string, len(w))
+	for i, d := range w {
+		names[i] = strings.ToLower(d.String()[:3])
+	}
+	return json.Marshal(names)
+}
+
+func (w *Weekdays) UnmarshalJSON(data []byte) error {
+	var specs []string
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return err
+	}
+	var days Weekdays
+	for _, spec := range specs {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "-")
+		first, ok := weekdayNames[from]
+		if !ok {
+			return fmt.Errorf("unknown day %q: use sun, mon, tue, wed, thu, fri or sat", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[to]; !ok {
+				return fmt.Errorf("unknown day %q: use sun, mon, tue, wed, thu, fri or sat", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	*w = days
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadConfig writes data to a config file and loads it
+func loadConfig(t *testing.T, data string) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadSameProductPerZIP(t *testing.T) {
+	saved := Products
+	defer func() { Products = saved }()
+
+	err := loadConfig(t, `{"zip": "10001", "products": [
+		{"id": "B0DT7L98J1", "name": "New York"},
+		{"id": "B0DT7L98J1", "name": "Seattle", "zip": "98101"}
+	]}`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(Products) != 2 {
+		t.Fatalf("Load kept %d product(s), want 2", len(Products))
+	}
+	first, second := Products[0], Products[1]
+	if first.ID != second.ID || first.ZIP != "10001" || second.ZIP != "98101" {
+		t.Errorf("products = %s@%s and %s@%s, want B0DT7L98J1 at 10001 and 98101", first.ID, first.ZIP, second.ID, second.ZIP)
+	}
+	if first.Key() == second.Key() {
+		t.Errorf("both entries have key %s", first.Key())
+	}
+}
+
+func TestLoadRejectsDuplicateEntries(t *testing.T) {
+	saved := Products
+	defer func() { Products = saved }()
+
+	tests := []string{
+		`{"products": [{"id": "B0DT7L98J1"}, {"url": "https://www.amazon.com/dp/B0DT7L98J1"}]}`,
+		`{"zip": "10001", "products": [{"id": "B0DT7L98J1"}, {"id": "b0dt7l98j1", "zip": "10001"}]}`,
+	}
+	for _, data := range tests {
+		err := loadConfig(t, data)
+		if err == nil || !strings.Contains(err.Error(), "product #2: B0DT7L98J1 is already listed as product #1") {
+			t.Errorf("Load(%s) = %v, want a duplicate product error", data, err)
+		}
+	}
+}
+
+func TestLoadCustomSourceDecimal(t *testing.T) {
+	savedSources, savedProducts := CustomSources, Products
+	defer func() { CustomSources, Products = savedSources, savedProducts }()
+
+	source := `{"sources": {"shop": {"url": "https://shop.example/{id}", "availability": {"css": "#stock"}%s}},
+		"products": [{"id": "rtx-5090-fe", "source": "shop"}]}`
+	if err := loadConfig(t, fmt.Sprintf(source, "")); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := CustomSources["shop"].Decimal; got != "." {
+		t.Errorf("default decimal = %q, want \".\"", got)
+	}
+	if err := loadConfig(t, fmt.Sprintf(source, `, "decimal": ","`)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := CustomSources["shop"].PriceFormat().Decimal; got != ',' {
+		t.Errorf("decimal = %q, want ','", got)
+	}
+	err := loadConfig(t, fmt.Sprintf(source, `, "decimal": "de"`))
+	if err == nil || !strings.Contains(err.Error(), "decimal") {
+		t.Errorf("Load with decimal \"de\" = %v, want a decimal error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import "strings"
+
+// Marketplace describes one Amazon storefront
+type Marketplace struct {
+	Domain       string   // Registrable domain, e.g. "amazon.co.uk"
+	Currency     string   // ISO 4217 code of listed prices
+	Decimal      byte     // Decimal separator in displayed prices
+	AddToCart    []string // Add-to-cart button labels
+	OutOfStock   []string // Phrases shown when the product can't be bought
+	AssociateTag string   // Affiliate tag added to cart links, if any
+	PAAPIRegion  string   // AWS region of the Product Advertising API endpoint
+}
+
+// Host returns the storefront's web host
+func (m Marketplace) Host() string {
+	return "www." + m.Domain
+}
+
+// englishOutOfStock is shown on every marketplace for some listings
+var englishOutOfStock = []string{"Out of Stock", "Sold Out", "Currently unavailable", "Temporarily out of stock"}
+
+// Marketplaces lists the supported Amazon storefronts; the first is the default
+var Marketplaces = []Marketplace{
+	{Domain: "amazon.com", PAAPIRegion: "us-east-1", Currency: "USD", Decimal: '.', AddToCart: []string{"Add to Cart"}, OutOfStock: englishOutOfStock, AssociateTag: "nisdisatc-20"},
+	{Domain: "amazon.ca", PAAPIRegion: "us-east-1", Currency: "CAD", Decimal: '.', AddToCart: []string{"Add to Cart", "Ajouter au panier"},
+		OutOfStock: append([]string{"Actuellement indisponible", "Rupture de stock"}, englishOutOfStock...)},
+	{Domain: "amazon.co.uk", PAAPIRegion: "eu-west-1", Currency: "GBP", Decimal: '.', AddToCart: []string{"Add to Basket", "Add to Cart"}, OutOfStock: englishOutOfStock},
+	{Domain: "amazon.com.au", PAAPIRegion: "us-west-2", Currency: "AUD", Decimal: '.', AddToCart: []string{"Add to Cart"}, OutOfStock: englishOutOfStock},
+	{Domain: "amazon.de", PAAPIRegion: "eu-west-1", Currency: "EUR", Decimal: ',', AddToCart: []string{"In den Einkaufswagen"},
+		OutOfStock: append([]string{"Derzeit nicht verfügbar", "Vorübergehend nicht auf Lager", "Nicht auf Lager", "Ausverkauft"}, englishOutOfStock...)},
+	{Domain: "amazon.fr", PAAPIRegion: "eu-west-1", Currency: "EUR", Decimal: ',', AddToCart: []string{"Ajouter au panier"},
+		OutOfStock: append([]string{"Actuellement indisponible", "Temporairement en rupture de stock", "Rupture de stock"}, englishOutOfStock...)},
+	{Domain: "amazon.it", PAAPIRegion: "eu-west-1", Currency: "EUR", Decimal: ',', AddToCart: []string{"Aggiungi al carrello"},
+		OutOfStock: append([]string{"Attualmente non disponibile", "Temporaneamente non disponibile", "Non disponibile"}, englishOutOfStock...)},
+	{Domain: "amazon.es", PAAPIRegion: "eu-west-1", Currency: "EUR", Decimal: ',', AddToCart: []string{"Añadir a la cesta"},
+		OutOfStock: append([]string{"No disponible por el momento", "Temporalmente sin stock", "Agotado"}, englishOutOfStock...)},
+	{Domain: "amazon.co.jp", PAAPIRegion: "us-west-2", Currency: "JPY", Decimal: '.', AddToCart: []string{"カートに入れる"},
+		OutOfStock: append([]string{"現在在庫切れです", "一時的に在庫切れ", "在庫切れ"}, englishOutOfStock...)},
+}
+
+// MarketplaceFor returns the storefront serving host, e.g. "www.amazon.de"
+func MarketplaceFor(host string) (Marketplace, bool) {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if i := strings.IndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+	for _, m := range Marketplaces {
+		if host == m.Domain || strings.HasSuffix(host, "."+m.Domain) {
+			return m, true
+		}
+	}
+	return Marketplace{}, false
+}
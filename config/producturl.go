@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Retailers
+const (
+	RetailerAmazon  = "amazon"
+	RetailerBestBuy = "bestbuy"
+	RetailerNewegg  = "newegg"
+	RetailerNVIDIA  = "nvidia" // Founders Edition store inventory API
+)
+
+// Ways of checking Amazon products
+const (
+	SourcePage  = "page"  // Scrape the product page
+	SourcePAAPI = "paapi" // Query the Product Advertising API
+)
+
+// ProductRef identifies a product at a retailer independently of the URL
+// it was pasted as
+type ProductRef struct {
+	Retailer string // One of the Retailer constants
+	Domain   string // Storefront domain, e.g. "amazon.de"
+	ID       string // Canonical product ID (ASIN for Amazon, SKU for Best Buy)
+}
+
+// URL returns the canonical product page
+func (r ProductRef) URL() string {
+	switch r.Retailer {
+	case RetailerBestBuy:
+		return "https://www." + r.Domain + "/site/" + r.ID + ".p?skuId=" + r.ID
+	case RetailerNewegg:
+		if strings.HasPrefix(r.ID, "Combo.") {
+			return "https://www." + r.Domain + "/Product/ComboDealDetails?ItemList=" + r.ID
+		}
+		return "https://www." + r.Domain + "/p/" + r.ID
+	}
+	return "https://www." + r.Domain + "/gp/product/" + r.ID + "/"
+}
+
+// ResolveShortLink expands short links such as amzn.to/… to the page they
+// redirect to. main installs a resolver; without one short links are rejected.
+var ResolveShortLink func(rawURL string) (string, error)
+
+// shortLinkHosts redirect to product pages
+var shortLinkHosts = map[string]bool{"amzn.to": true, "amzn.eu": true, "amzn.asia": true, "a.co": true}
+
+var (
+	asinPattern       = regexp.MustCompile(`^(B0[A-Z0-9]{8}|[0-9]{9}[0-9X])$`)
+	skuPattern        = regexp.MustCompile(`^[0-9]{7,8}$`)
+	skuPathPattern    = regexp.MustCompile(`/([0-9]{7,8})\.p(?:[/?]|$)`)
+	nvidiaSKUPattern  = regexp.MustCompile(`^[A-Z0-9_]{4,24}$`)
+	neweggItemPattern = regexp.MustCompile(`^(N82E168[0-9]{8}|9SI[A-Z0-9]{9,13}|Combo\.[0-9]{5,9})$`)
+	neweggDashPattern = regexp.MustCompile(`^[0-9]{2}-[0-9]{3}-[0-9]{3}$`)
+	asinPathPattern   = regexp.MustCompile(`(?i)/(?:dp|gp/product|gp/aw/d|exec/obidos/asin|o/asin|product)/([A-Z0-9]{10})(?:[/?]|$)`)
+)
+
+// ValidASIN reports whether id looks like an Amazon product ID: "B0"
+// followed by eight letters or digits, or an ISBN-10 for books
+func ValidASIN(id string) bool {
+	return asinPattern.MatchString(id)
+}
+
+// ValidSKU reports whether id looks like a Best Buy SKU
+func ValidSKU(id string) bool {
+	return skuPattern.MatchString(id)
+}
+
+// NormalizeNeweggItem returns the canonical form of a Newegg item number:
+// N82E16814126659 for Newegg items (also accepted as 14-126-659),
+// 9SI… for marketplace items and Combo.1234567 for combo deals
+func NormalizeNeweggItem(id string) (string, bool) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if neweggDashPattern.MatchString(id) {
+		id = "N82E168" + strings.ReplaceAll(id, "-", "")
+	}
+	if strings.HasPrefix(id, "COMBO.") {
+		id = "Combo." + id[len("COMBO."):]
+	}
+	return id, neweggItemPattern.MatchString(id)
+}
+
+// ParseProductURL extracts the retailer and canonical product ID from any
+// product link: /dp/ and /gp/product/ paths, links with tracking parameters,
+// smile.amazon.* and mobile hosts, and short links when a resolver is set
+func ParseProductURL(rawURL string) (ProductRef, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ProductRef{}, fmt.Errorf("%q is not a valid URL", rawURL)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	if shortLinkHosts[host] {
+		if ResolveShortLink == nil {
+			return ProductRef{}, fmt.Errorf("%s is a short link; open it in a browser and paste the full product URL", rawURL)
+		}
+		expanded, err := ResolveShortLink(rawURL)
+		if err != nil {
+			return ProductRef{}, fmt.Errorf("failed to expand short link %s: %w", rawURL, err)
+		}
+		if expanded == rawURL {
+			return ProductRef{}, fmt.Errorf("short link %s did not redirect to a product page", rawURL)
+		}
+		return ParseProductURL(expanded)
+	}
+
+	if host == "bestbuy.com" || strings.HasSuffix(host, ".bestbuy.com") {
+		return parseBestBuyURL(u, rawURL)
+	}
+
+	if host == "newegg.com" || strings.HasSuffix(host, ".newegg.com") {
+		return parseNeweggURL(u, rawURL)
+	}
+
+	market, ok := MarketplaceFor(host)
+	if !ok {
+		return ProductRef{}, fmt.Errorf("unsupported retailer host %q; supported: %s", u.Hostname(), strings.Join(SupportedHosts(), ", "))
+	}
+
+	id := ""
+	if match := asinPathPattern.FindStringSubmatch(u.EscapedPath()); match != nil {
+		id = strings.ToUpper(match[1])
+	} else if asin := u.Query().Get("asin"); asin != "" {
+		id = strings.ToUpper(asin)
+	} else {
+		return ProductRef{}, fmt.Errorf("no product ID found in %s; use a product page link containing /dp/<ASIN>", rawURL)
+	}
+	if !ValidASIN(id) {
+		return ProductRef{}, fmt.Errorf("%q in %s is not a valid ASIN", id, rawURL)
+	}
+	return ProductRef{Retailer: RetailerAmazon, Domain: market.Domain, ID: id}, nil
+}
+
+// parseBestBuyURL extracts the SKU from /site/<name>/<sku>.p?skuId=<sku> links
+func parseBestBuyURL(u *url.URL, rawURL string) (ProductRef, error) {
+	id := u.Query().Get("skuId")
+	if id == "" {
+		if match := skuPathPattern.FindStringSubmatch(u.Path); match != nil {
+			id = match[1]
+		}
+	}
+	if id == "" {
+		return ProductRef{}, fmt.Errorf("no SKU found in %s; use a product page link ending in <sku>.p", rawURL)
+	}
+	if !ValidSKU(id) {
+		return ProductRef{}, fmt.Errorf("%q in %s is not a valid Best Buy SKU", id, rawURL)
+	}
+	return ProductRef{Retailer: RetailerBestBuy, Domain: "bestbuy.com", ID: id}, nil
+}
+
+// parseNeweggURL extracts the item number from /p/<item> links, including
+// Item= and ItemList= query parameters of older and combo links
+func parseNeweggURL(u *url.URL, rawURL string) (ProductRef, error) {
+	candidate := ""
+	if i := strings.Index(u.Path, "/p/"); i >= 0 {
+		candidate = strings.SplitN(u.Path[i+len("/p/"):], "/", 2)[0]
+	}
+	for _, key := range []string{"Item", "ItemList", "item"} {
+		if candidate == "" {
+			candidate = u.Query().Get(key)
+		}
+	}
+	if candidate == "" {
+		return ProductRef{}, fmt.Errorf("no item number found in %s; use a product link containing /p/<item>", rawURL)
+	}
+	id, ok := NormalizeNeweggItem(candidate)
+	if !ok {
+		return ProductRef{}, fmt.Errorf("%q in %s is not a valid Newegg item number", candidate, rawURL)
+	}
+	return ProductRef{Retailer: RetailerNewegg, Domain: "newegg.com", ID: id}, nil
+}
+
+// SupportedHosts lists the hosts product URLs may point to
+func SupportedHosts() []string {
+	var hosts []string
+	for _, m := range Marketplaces {
+		hosts = append(hosts, m.Domain)
+	}
+	return append(hosts, "bestbuy.com", "newegg.com")
+}
+
+// ErrInvalidASIN is returned for product IDs that can't be Amazon ASINs
+var ErrInvalidASIN = errors.New("not a valid ASIN (expected 10 characters like B0DVCH9WJH)")
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeNeweggItem(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+		ok   bool
+	}{
+		{"N82E16814126659", "N82E16814126659", true},
+		{" n82e16814126659 ", "N82E16814126659", true},
+		{"14-126-659", "N82E16814126659", true},
+		{"9SIA7ABKAB1234", "9SIA7ABKAB1234", true},
+		{"9sia7abkab1234", "9SIA7ABKAB1234", true},
+		{"Combo.4567890", "Combo.4567890", true},
+		{"COMBO.4567890", "Combo.4567890", true},
+		{"combo.4567890", "Combo.4567890", true},
+		{"N82E1681412665", "N82E1681412665", false}, // One digit short
+		{"14-126-65", "14-126-65", false},
+		{"Combo.12", "Combo.12", false},
+		{"B0DT7L98J1", "B0DT7L98J1", false}, // An ASIN
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeNeweggItem(tt.id)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeNeweggItem(%q) = %q, %t, want %q, %t", tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseProductURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want ProductRef
+	}{
+		{"https://www.amazon.com/dp/B0DT7L98J1", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://www.amazon.com/NVIDIA-GeForce-RTX-5090/dp/B0DT7L98J1/ref=sr_1_1?tag=xyz&th=1", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://www.amazon.com/gp/product/B0DT7L98J1/", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://www.amazon.com/gp/product/B0DT7L98J1?psc=1#customerReviews", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://www.amazon.de/-/en/product/B0DT7L98J1", ProductRef{RetailerAmazon, "amazon.de", "B0DT7L98J1"}},
+		{"https://www.amazon.co.uk/Some-Slug/product/B0DT7L98J1/ref=x", ProductRef{RetailerAmazon, "amazon.co.uk", "B0DT7L98J1"}},
+		{"https://www.amazon.com/dp/B0DT7L98J1#product-details", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://www.amazon.com/s?k=rtx&asin=B0DT7L98J1&utm_source=x", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://smile.amazon.com/dp/B0DT7L98J1", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"https://m.amazon.co.jp/gp/aw/d/B0DT7L98J1", ProductRef{RetailerAmazon, "amazon.co.jp", "B0DT7L98J1"}},
+		{"https://www.amazon.com/dp/b0dt7l98j1", ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}},
+		{"amazon.fr/dp/B0DT7L98J1", ProductRef{RetailerAmazon, "amazon.fr", "B0DT7L98J1"}},
+		{"  https://WWW.AMAZON.CA/dp/B0DT7L98J1  ", ProductRef{RetailerAmazon, "amazon.ca", "B0DT7L98J1"}},
+		{"https://www.amazon.com/dp/0316769487", ProductRef{RetailerAmazon, "amazon.com", "0316769487"}}, // ISBN-10
+		{"https://www.bestbuy.com/site/nvidia-geforce-rtx-5090/6614151.p?skuId=6614151", ProductRef{RetailerBestBuy, "bestbuy.com", "6614151"}},
+		{"https://www.newegg.com/p/N82E16814126659?Item=N82E16814126659", ProductRef{RetailerNewegg, "newegg.com", "N82E16814126659"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseProductURL(tt.url)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseProductURL(%q) = %+v, %v, want %+v", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseProductURLErrors(t *testing.T) {
+	tests := []struct {
+		url     string
+		errText string
+	}{
+		{"https://www.amazon.com/dp/B0DT7L98J", `no product ID found in https://www.amazon.com/dp/B0DT7L98J`}, // 9 characters
+		{"https://www.amazon.com/dp/B0DT7L98J12", `no product ID found`},                                      // 11 characters
+		{"https://www.amazon.com/dp/A0DT7L98J1", `"A0DT7L98J1" in https://www.amazon.com/dp/A0DT7L98J1 is not a valid ASIN`},
+		{"https://www.amazon.com/dp/B0DT7L98J!", `no product ID found`},
+		{"https://www.amazon.com/s?k=rtx+5090", `no product ID found in https://www.amazon.com/s?k=rtx+5090; use a product page link containing /dp/<ASIN>`},
+		{"https://www.ebay.com/itm/123456789", `unsupported retailer host "www.ebay.com"; supported: amazon.com, amazon.ca`},
+		{"https://amazon.com.evil.example/dp/B0DT7L98J1", `unsupported retailer host "amazon.com.evil.example"`},
+		{"https://", `"https://" is not a valid URL`},
+	}
+	for _, tt := range tests {
+		_, err := ParseProductURL(tt.url)
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("ParseProductURL(%q) = %v, want an error containing %q", tt.url, err, tt.errText)
+		}
+	}
+}
+
+func TestParseShortLink(t *testing.T) {
+	saved := ResolveShortLink
+	defer func() { ResolveShortLink = saved }()
+
+	ResolveShortLink = nil
+	if _, err := ParseProductURL("https://amzn.to/3AbCdEf"); err == nil || !strings.Contains(err.Error(), "is a short link") {
+		t.Errorf("short link without a resolver: %v", err)
+	}
+
+	redirects := map[string]string{
+		"https://amzn.to/3AbCdEf": "https://www.amazon.com/NVIDIA-GeForce-RTX-5090/dp/B0DT7L98J1?tag=xyz&linkCode=ll1",
+		"https://a.co/d/stuck":    "https://a.co/d/stuck",
+	}
+	ResolveShortLink = func(rawURL string) (string, error) {
+		if expanded, ok := redirects[rawURL]; ok {
+			return expanded, nil
+		}
+		return "", errors.New("404 Not Found")
+	}
+	got, err := ParseProductURL("amzn.to/3AbCdEf")
+	if want := (ProductRef{RetailerAmazon, "amazon.com", "B0DT7L98J1"}); err != nil || got != want {
+		t.Errorf("ParseProductURL(amzn.to) = %+v, %v, want %+v", got, err, want)
+	}
+	if _, err := ParseProductURL("https://a.co/d/stuck"); err == nil || !strings.Contains(err.Error(), "did not redirect to a product page") {
+		t.Errorf("short link without a redirect: %v", err)
+	}
+	if _, err := ParseProductURL("https://amzn.eu/d/gone"); err == nil || !strings.Contains(err.Error(), "failed to expand short link https://amzn.eu/d/gone: 404 Not Found") {
+		t.Errorf("short link that fails to expand: %v", err)
+	}
+}
+
+func TestValidASIN(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"B0DT7L98J1", true},
+		{"0316769487", true},
+		{"031676948X", true},
+		{"b0dt7l98j1", false}, // Callers upper-case IDs first
+		{"B0DT7L98J", false},
+		{"B0DT7L98J12", false},
+		{"C0DT7L98J1", false},
+		{"B0DT7L-8J1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidASIN(tt.id); got != tt.want {
+			t.Errorf("ValidASIN(%q) = %t, want %t", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Restock is a run of successful checks that found a product in stock after
+// one that found it out of stock
+type Restock struct {
+	Start    time.Time  `json:"start"`         // First check that found it in stock
+	End      *time.Time `json:"end,omitempty"` // First check that found it out of stock again, nil if it still was at the last check
+	Duration float64    `json:"duration_seconds"`
+	Price    float64    `json:"price,omitempty"` // Price at Start, 0 if unknown
+	Currency string     `json:"currency,omitempty"`
+}
+
+// Tracker finds the restocks in one product's successful checks. The first
+// check only sets the starting state, so a product that was already in stock
+// then does not count as restocked and the end of that run is not reported.
+type Tracker struct {
+	checked bool // A check was observed
+	inStock bool // The last check found the product in stock
+	open    bool // A restock started and has not ended
+}
+
+// Observe records a successful check in time order and reports whether it
+// started a restock or ended one
+func (t *Tracker) Observe(inStock bool) (started, ended bool) {
+	was := t.inStock
+	first := !t.checked
+	t.checked, t.inStock = true, inStock
+	switch {
+	case first:
+	case inStock && !was:
+		t.open = true
+		return true, false
+	case !inStock && was && t.open:
+		t.open = false
+		return false, true
+	}
+	return false, false
+}
+
+// Stats are the restock patterns of one product. Durations are measured
+// between checks, so they are only as precise as the polling interval.
+type Stats struct {
+	ProductID string    `json:"product_id"`
+	Product   string    `json:"product,omitempty"`
+	Checks    int       `json:"checks"` // Successful checks
+	First     time.Time `json:"first_check"`
+	Last      time.Time `json:"last_check"`
+	TimeZone  string    `json:"time_zone"` // Zone of the histograms
+
+	Restocks       []Restock `json:"restocks"`
+	InStock        float64   `json:"in_stock_seconds"`        // Time in stock over all restocks
+	MedianInStock  float64   `json:"median_in_stock_seconds"` // Of restocks that ended
+	MedianBetween  float64   `json:"median_between_seconds"`  // Between the starts of consecutive restocks, 0 with fewer than two
+	ByHour         [24]int   `json:"by_hour"`                 // Restocks by hour of the day they started
+	ByWeekday      [7]int    `json:"by_weekday"`              // Restocks by day of the week they started, Sunday first
+	MinPrice       float64   `json:"min_price,omitempty"`     // Prices at restock, 0 if none was known
+	MedianPrice    float64   `json:"median_price,omitempty"`
+	MaxPrice       float64   `json:"max_price,omitempty"`
+	PriceCurrency  string    `json:"price_currency,omitempty"`
+	StartedInStock bool      `json:"started_in_stock,omitempty"` // The first check found it in stock; that run is not counted
+}
+
+// Analyze finds the restocks of every product in entries. zone returns the
+// time zone a product's histograms use. Products are returned in the order
+// of their first successful check.
+func Analyze(entries []Entry, zone func(productID string) *time.Location) []*Stats {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	order := []*Stats{}
+	byProduct := make(map[string]*Stats)
+	trackers := make(map[string]*Tracker)
+	for _, entry := range sorted {
+		if entry.Outcome != "success" {
+			continue
+		}
+		stats, ok := byProduct[entry.ProductID]
+		if !ok {
+			stats = &Stats{ProductID: entry.ProductID, First: entry.Time, StartedInStock: entry.InStock}
+			byProduct[entry.ProductID] = stats
+			order = append(order, stats)
+			trackers[entry.ProductID] = &Tracker{}
+		}
+		if entry.Product != "" {
+			stats.Product = entry.Product
+		}
+		stats.Checks++
+		stats.Last = entry.Time
+
+		switch started, ended := trackers[entry.ProductID].Observe(entry.InStock); {
+		case started:
+			stats.Restocks = append(stats.Restocks, Restock{Start: entry.Time, Price: entry.Price, Currency: entry.Currency})
+		case ended:
+			end := entry.Time
+			stats.Restocks[len(stats.Restocks)-1].End = &end
+		}
+	}
+
+	for _, stats := range order {
+		stats.summarize(zone(stats.ProductID))
+	}
+	return order
+}
+
+// summarize fills in the totals, medians and histograms from Restocks
+func (s *Stats) summarize(loc *time.Location) {
+	s.TimeZone = loc.String()
+	if s.Restocks == nil {
+		s.Restocks = []Restock{}
+	}
+
+	var ended, between, prices []float64
+	for i := range s.Restocks {
+		restock := &s.Restocks[i]
+		end := s.Last
+		if restock.End != nil {
+			end = *restock.End
+		}
+		restock.Duration = end.Sub(restock.Start).Seconds()
+		s.InStock += restock.Duration
+		if restock.End != nil {
+			ended = append(ended, restock.Duration)
+		}
+		if i > 0 {
+			between = append(between, restock.Start.Sub(s.Restocks[i-1].Start).Seconds())
+		}
+
+		start := restock.Start.In(loc)
+		s.ByHour[start.Hour()]++
+		s.ByWeekday[start.Weekday()]++
+
+		if restock.Price > 0 && (s.PriceCurrency == "" || restock.Currency == s.PriceCurrency) {
+			s.PriceCurrency = restock.Currency
+			prices = append(prices, restock.Price)
+		}
+	}
+	s.MedianInStock = median(ended)
+	s.MedianBetween = median(between)
+	if len(prices) > 0 {
+		sort.Float64s(prices)
+		s.MinPrice, s.MaxPrice = prices[0], prices[len(prices)-1]
+		s.MedianPrice = median(prices)
+	}
+}
+
+// median returns the median of values, 0 if there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []bool
+		want   string // s for a started restock, e for an ended one, . otherwise
+	}{
+		{"starts out of stock", []bool{false, true, true, false, true}, ".s.es"},
+		{"starts in stock", []bool{true, true, false, true, false}, "...se"},
+		{"never in stock", []bool{false, false}, ".."},
+		{"always in stock", []bool{true, true}, ".."},
+	}
+	for _, tt := range tests {
+		var tracker Tracker
+		got := ""
+		for _, inStock := range tt.checks {
+			switch started, ended := tracker.Observe(inStock); {
+			case started:
+				got += "s"
+			case ended:
+				got += "e"
+			default:
+				got += "."
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: Observe = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	entries := []Entry{
+		{Time: at(0), ProductID: "A", Outcome: "success", InStock: true},
+		{Time: at(10), ProductID: "A", Outcome: "success", InStock: false},
+		{Time: at(20), ProductID: "A", Outcome: "blocked", InStock: false},
+		{Time: at(30), ProductID: "A", Outcome: "success", InStock: true, Price: 1999, Currency: "USD"},
+		{Time: at(40), ProductID: "A", Outcome: "success", InStock: false},
+		{Time: at(50), ProductID: "A", Outcome: "success", InStock: true, Price: 2099, Currency: "USD"},
+		{Time: at(70), ProductID: "A", Outcome: "success", InStock: true},
+	}
+	stats := Analyze(entries, func(string) *time.Location { return time.UTC })
+	if len(stats) != 1 {
+		t.Fatalf("Analyze found %d product(s), want 1", len(stats))
+	}
+	s := stats[0]
+	if !s.StartedInStock || s.Checks != 6 || len(s.Restocks) != 2 {
+		t.Fatalf("StartedInStock = %t, Checks = %d, Restocks = %d, want true, 6, 2", s.StartedInStock, s.Checks, len(s.Restocks))
+	}
+	if r := s.Restocks[0]; !r.Start.Equal(at(30)) || r.End == nil || !r.End.Equal(at(40)) || r.Duration != 600 {
+		t.Errorf("first restock = %+v, want 09:30 to 09:40", r)
+	}
+	if r := s.Restocks[1]; !r.Start.Equal(at(50)) || r.End != nil || r.Duration != 1200 {
+		t.Errorf("second restock = %+v, want 09:50 still in stock", r)
+	}
+	if s.InStock != 1800 || s.MedianInStock != 600 || s.MedianBetween != 1200 {
+		t.Errorf("InStock, MedianInStock, MedianBetween = %v, %v, %v, want 1800, 600, 1200", s.InStock, s.MedianInStock, s.MedianBetween)
+	}
+	if s.MinPrice != 1999 || s.MaxPrice != 2099 || s.MedianPrice != 2049 || s.ByHour[9] != 2 {
+		t.Errorf("prices %v-%v median %v, %d at 9h, want 1999-2099 median 2049, 2", s.MinPrice, s.MaxPrice, s.MedianPrice, s.ByHour[9])
+	}
+}
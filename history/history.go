@@ -0,0 +1,123 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one recorded stock check
+type Entry struct {
+	Time      time.Time `json:"time"`
+	ProductID string    `json:"product_id"`
+	Product   string    `json:"product,omitempty"`
+	Outcome   string    `json:"outcome"`
+	InStock   bool      `json:"in_stock"`
+	Indicator string    `json:"indicator,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	Price     float64   `json:"price,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	Seller    string    `json:"seller,omitempty"`
+	Condition string    `json:"condition,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Alerted   bool      `json:"alerted,omitempty"`
+	Rule      string    `json:"rule,omitempty"` // Alert rule that matched; Alerted is false if the alert was deduplicated
+}
+
+// Store appends entries to a JSON Lines file. Writes are buffered until
+// Flush or Close.
+type Store struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// Open opens (or creates) the history file at path for appending
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	return &Store{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Record appends an entry
+func (s *Store) Record(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("history store is closed")
+	}
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history entry: %w", err)
+	}
+	return nil
+}
+
+// Flush writes buffered entries to disk
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Close flushes pending entries and closes the file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
+
+func (s *Store) flush() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush history: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Load reads every entry from the history file at path
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("history line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, nil
+}
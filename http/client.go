@@ -1,17 +1,13 @@
 package http
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
-	"net/http/cookiejar"
-	"net/url"
-	"os"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"gpu-sniper/config"
@@ -27,28 +23,6 @@ var userAgents = []string{
 	"Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Mobile/15E148 Safari/604.1",
 }
 
-// New variables for session persistence
-var (
-	CurrentUserAgent string
-	cookieFile       = "cookies.json"
-	cookieMutex      sync.Mutex
-	cookieChan       = make(chan map[string][]*http.Cookie, 1) // Buffered channel
-)
-
-// Update the HttpClient definition
-var (
-	cookieJar, _ = cookiejar.New(nil)
-	HttpClient   = &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 100,
-			IdleConnTimeout:     90 * time.Second,
-		},
-		Jar: cookieJar,
-	}
-)
-
 // Update relatedURLs with more Amazon-specific paths
 var (
     relatedURLs = []string{
@@ -64,91 +38,21 @@ var (
     visitThreshold = 8 // Visit related page every ~8 checks
 )
 
-// New init function for session persistence
-func init() {
-	CurrentUserAgent = userAgents[rand.Intn(len(userAgents))]
-	loadCookiesFromFile()
-	go periodicCookieSave()
-	go cookieSaver() // Start the cookie saving goroutine
-}
-
-// Load cookies from file and set them in the cookie jar
-func loadCookiesFromFile() {
-	data, err := os.ReadFile(cookieFile)
-	if err != nil {
-		return
-	}
-	var store map[string][]*http.Cookie
-	if err = json.Unmarshal(data, &store); err != nil {
-		return
-	}
-	// Load cookies for each domain
-	for domain, cookies := range store {
-		u := &url.URL{Scheme: "https", Host: domain}
-		cookieJar.SetCookies(u, cookies)
-	}
-}
-
-// Save cookies for the retailer domain to file
-func saveCookiesToFile() {
-	u, err := url.Parse(config.RetailerURL)
-	if err != nil {
-		return
-	}
-	cookies := cookieJar.Cookies(u)
-	store := map[string][]*http.Cookie{
-		u.Host: cookies,
-	}
-	// Send cookies to the channel for saving
-	select {
-	case cookieChan <- store:
-		// Successfully sent
-	default:
-		// Channel is full; don't block
-		ui.LogWarning("Cookie save channel is full, skipping save")
-	}
-}
-
-// Cookie saver goroutine
-func cookieSaver() {
-	for cookies := range cookieChan {
-		cookieMutex.Lock()
-		data, err := json.Marshal(cookies)
-		cookieMutex.Unlock()
-		if err != nil {
-			ui.LogError("Error marshaling cookies: %v", err)
-			continue
-		}
-		err = os.WriteFile(cookieFile, data, 0644)
-		if err != nil {
-			ui.LogError("Error writing cookie file: %v", err)
-		}
-	}
-}
-
-// Periodically save cookies every 30 seconds
-func periodicCookieSave() {
-	ticker := time.NewTicker(30 * time.Second)
-	for range ticker.C {
-		saveCookiesToFile()
-	}
-}
-
-// FetchRetailerPage fetches the HTML content of the retailer page with retry logic
-func FetchRetailerPage() (string, error) {
+// FetchRetailerPage fetches the HTML content of a retailer page with retry logic
+func (s *Session) FetchRetailerPage(ctx context.Context, pageURL string) (string, error) {
 	var responseBody string
 	
-	operation := func() error {
-		req, err := http.NewRequest("GET", config.RetailerURL, nil)
+	operation := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
-		req.Header.Set("User-Agent", getRandomUserAgent())
+		req.Header.Set("User-Agent", s.UserAgent)
 		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
 		req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 
-		ui.LogInfo("Fetching page: %s", config.RetailerURL)
-		resp, err := HttpClient.Do(req)
+		ui.LogInfo("Fetching page: %s", pageURL)
+		resp, err := s.Client.Do(req)
 		if err != nil {
 			return fmt.Errorf("network error: failed to fetch page. Please check your internet connection: %w", err)
 		}
@@ -158,7 +62,7 @@ func FetchRetailerPage() (string, error) {
 			return fmt.Errorf("HTTP error %d: failed to fetch page. This may be due to rate limiting or server-side issues. Please try again later", resp.StatusCode)
 		}
 
-		body, err := io.ReadAll(resp.Body)
+		body, err := ReadDecodedBody(resp)
 		if err != nil {
 			return fmt.Errorf("failed to read response: %w", err)
 		}
@@ -169,7 +73,7 @@ func FetchRetailerPage() (string, error) {
 	}
 
 	// Execute operation with retry logic - use DefaultRetryConfig since this is a standard fetch
-	err := utils.RetryOperation(operation, config.DefaultRetryConfig)
+	err := utils.RetryOperation(ctx, operation, config.DefaultRetryConfig)
 	if err != nil {
 		ui.LogError("All retry attempts failed: %v", err)
 		return "", err
@@ -178,13 +82,14 @@ func FetchRetailerPage() (string, error) {
 	return responseBody, nil
 }
 
-// CreateRequest creates an HTTP request with appropriate headers
-func CreateRequest() (*http.Request, error) {
-	req, err := http.NewRequest("GET", config.RetailerURL, nil)
+// CreateRequest creates an HTTP request for pageURL with appropriate headers.
+// The request is aborted when ctx is cancelled.
+func (s *Session) CreateRequest(ctx context.Context, pageURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", getRandomUserAgent())
+	req.Header.Set("User-Agent", s.UserAgent)
 	// Add more realistic browser headers
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
     req.Header.Set("Accept-Language", "en-US,en;q=0.8")
@@ -200,18 +105,13 @@ func CreateRequest() (*http.Request, error) {
 	return req, nil
 }
 
-// Modify getRandomUserAgent to return a consistent session agent
-func getRandomUserAgent() string {
-	return CurrentUserAgent
-}
-
 // Enhanced VisitRelatedPage function with more natural browsing behavior
-func VisitRelatedPage() {
+func (s *Session) VisitRelatedPage(ctx context.Context, productURL string) {
     if rand.Intn(visitThreshold) != 0 {
         return // Don't visit every time
     }
     
-    baseURL := extractBaseURL(config.RetailerURL)
+    baseURL := ExtractBaseURL(productURL)
     if baseURL == "" {
         return
     }
@@ -230,16 +130,18 @@ func VisitRelatedPage() {
         browsePage := baseURL + randomPath
         
         // Add random delay between page visits (1-3 seconds)
-        time.Sleep(time.Duration(1000+rand.Intn(2000)) * time.Millisecond)
+        if utils.Sleep(ctx, time.Duration(1000+rand.Intn(2000))*time.Millisecond) != nil {
+            return // Shutting down
+        }
         
         // Visit the related page
-        req, err := http.NewRequest("GET", browsePage, nil)
+        req, err := http.NewRequestWithContext(ctx, "GET", browsePage, nil)
         if err != nil {
             continue
         }
         
         // Use consistent headers for the session
-        req.Header.Set("User-Agent", CurrentUserAgent)
+        req.Header.Set("User-Agent", s.UserAgent)
         req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
         req.Header.Set("Accept-Language", "en-US,en;q=0.8")
 		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
@@ -257,14 +159,13 @@ func VisitRelatedPage() {
             req.Header.Set("Referer", baseURL)
         }
         
-        resp, err := HttpClient.Do(req)
+        resp, err := s.Client.Do(req)
         if err != nil {
             continue
         }
         
-        // Read the body content
-        body, err := io.ReadAll(resp.Body)
-        resp.Body.Close()
+        // Read the decoded body content
+        body, err := ReadDecodedBody(resp)
         
         if err == nil {
             // Sometimes follow internal links (25% chance)
@@ -272,14 +173,19 @@ func VisitRelatedPage() {
                 internalLink := extractRandomInternalLink(string(body), baseURL)
                 if internalLink != "" && internalLink != browsePage {
                     // Add a more natural delay before clicking internal link (1.5-4.5 seconds)
-                    time.Sleep(time.Duration(1500+rand.Intn(3000)) * time.Millisecond)
+                    if utils.Sleep(ctx, time.Duration(1500+rand.Intn(3000))*time.Millisecond) != nil {
+                        return // Shutting down
+                    }
                     
                     // Visit internal link with retry logic
-                    internalOperation := func() error {
-                        internalReq, _ := http.NewRequest("GET", internalLink, nil)
-                        internalReq.Header.Set("User-Agent", CurrentUserAgent)
+                    internalOperation := func(ctx context.Context) error {
+                        internalReq, err := http.NewRequestWithContext(ctx, "GET", internalLink, nil)
+                        if err != nil {
+                            return err
+                        }
+                        internalReq.Header.Set("User-Agent", s.UserAgent)
                         internalReq.Header.Set("Referer", browsePage)
-                        internalResp, err := HttpClient.Do(internalReq)
+                        internalResp, err := s.Client.Do(internalReq)
                         
                         if err != nil {
                             return fmt.Errorf("failed to fetch internal link: %w", err)
@@ -292,7 +198,7 @@ func VisitRelatedPage() {
                     }
                     
                     // Use RelatedPageRetryConfig for internal link navigation
-                    _ = utils.RetryOperation(internalOperation, config.RelatedPageRetryConfig)
+                    _ = utils.RetryOperation(ctx, internalOperation, config.RelatedPageRetryConfig)
                 }
             }
         }
@@ -342,7 +248,8 @@ func extractRandomInternalLink(htmlContent, baseURL string) string {
     return ""
 }
 
-func extractBaseURL(url string) string {
+// ExtractBaseURL returns the scheme and host of a URL, or "" if it has none
+func ExtractBaseURL(url string) string {
     // Simple extraction - would need more robust parsing in production
     parts := strings.Split(url, "/")
     if len(parts) >= 3 {
@@ -0,0 +1,374 @@
+package http
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// StoredCookie is a cookie as persisted in the cookie file, with the
+// domain, path and expiry the jar itself does not expose
+type StoredCookie struct {
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`              // Host the cookie belongs to, without a leading dot
+	HostOnly bool          `json:"host_only,omitempty"` // Sent to Domain only, not to its subdomains
+	Path     string        `json:"path"`
+	Expires  time.Time     `json:"expires,omitzero"` // Zero for session cookies
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// Expired reports whether the cookie has expired at now
+func (c StoredCookie) Expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+func (c StoredCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// persistentJar is a cookie jar that also remembers every cookie's full
+// attributes so the jar can be saved and restored. Only cookies the jar
+// accepts are remembered.
+type persistentJar struct {
+	*cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[string]StoredCookie
+}
+
+func newPersistentJar() (*persistentJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	return &persistentJar{Jar: jar, cookies: make(map[string]StoredCookie)}, nil
+}
+
+// SetCookies stores cookies received from u in the jar and records them
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, cookie := range cookies {
+		stored, ok := storedFrom(u, cookie, now)
+		if !ok {
+			continue // Rejected by the jar
+		}
+		if stored.Expired(now) {
+			delete(j.cookies, stored.key())
+			continue
+		}
+		j.cookies[stored.key()] = stored
+	}
+}
+
+// restore puts saved cookies back into the jar, skipping expired ones and
+// ones the jar would not have accepted from their domain
+func (j *persistentJar) restore(cookies []StoredCookie, now time.Time) (loaded, pruned, rejected int) {
+	for _, stored := range cookies {
+		if stored.Name == "" || stored.Domain == "" {
+			continue
+		}
+		domainAttr := stored.Domain
+		if stored.HostOnly {
+			domainAttr = ""
+		}
+		if domain, hostOnly, ok := cookieDomain(stored.Domain, domainAttr); !ok || domain != stored.Domain || hostOnly != stored.HostOnly {
+			rejected++
+			continue
+		}
+		if stored.Expired(now) {
+			pruned++
+			continue
+		}
+		if stored.Path == "" {
+			stored.Path = "/"
+		}
+		cookie := &http.Cookie{
+			Name:     stored.Name,
+			Value:    stored.Value,
+			Path:     stored.Path,
+			Expires:  stored.Expires,
+			Secure:   stored.Secure,
+			HttpOnly: stored.HttpOnly,
+			SameSite: stored.SameSite,
+		}
+		if !stored.HostOnly {
+			cookie.Domain = stored.Domain
+		}
+		u := &url.URL{Scheme: "https", Host: stored.Domain, Path: stored.Path}
+		j.Jar.SetCookies(u, []*http.Cookie{cookie})
+
+		j.mu.Lock()
+		j.cookies[stored.key()] = stored
+		j.mu.Unlock()
+		loaded++
+	}
+	return loaded, pruned, rejected
+}
+
+// snapshot returns every unexpired cookie, sorted by domain, path and name
+func (j *persistentJar) snapshot(now time.Time) []StoredCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookies := make([]StoredCookie, 0, len(j.cookies))
+	for key, stored := range j.cookies {
+		if stored.Expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		cookies = append(cookies, stored)
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+// storedFrom resolves a cookie's domain, path and expiry the way the jar
+// does. It reports false for cookies the jar rejects.
+func storedFrom(u *url.URL, cookie *http.Cookie, now time.Time) (StoredCookie, bool) {
+	domain, hostOnly, ok := cookieDomain(u.Hostname(), cookie.Domain)
+	if !ok {
+		return StoredCookie{}, false
+	}
+	stored := StoredCookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   domain,
+		HostOnly: hostOnly,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		SameSite: cookie.SameSite,
+	}
+	if stored.Path == "" || !strings.HasPrefix(stored.Path, "/") {
+		stored.Path = defaultCookiePath(u.Path)
+	}
+	switch {
+	case cookie.MaxAge < 0:
+		stored.Expires = now // Deletion request
+	case cookie.MaxAge > 0:
+		stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		stored.Expires = cookie.Expires
+	}
+	return stored, true
+}
+
+// cookieDomain resolves the domain attribute of a cookie set by host the way
+// the jar does (RFC 6265 5.3 steps 5 and 6): the domain must domain-match
+// host and must not be a public suffix such as "com" or "co.uk"
+func cookieDomain(host, domain string) (resolved string, hostOnly, ok bool) {
+	host = strings.ToLower(host)
+	if host == "" {
+		return "", false, false
+	}
+	if domain == "" {
+		return host, true, true
+	}
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if net.ParseIP(host) != nil {
+		return host, true, domain == host
+	}
+	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false, false
+	}
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+		// A public suffix is only allowed as the host itself
+		return host, true, host == domain
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// defaultCookiePath is the directory of the request path (RFC 6265 5.1.4)
+func defaultCookiePath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	dir := path.Dir(requestPath)
+	if dir == "." {
+		return "/"
+	}
+	return dir
+}
+
+// Cookie file format
+const (
+	cookieFileVersion = 1
+	cookieKDFRounds   = 600000 // PBKDF2-SHA256 iterations for encrypted files
+	cookieSaltSize    = 16
+)
+
+// ErrCookiePassphrase is returned when an encrypted cookie file cannot be
+// opened with the given passphrase
+var ErrCookiePassphrase = errors.New("cookie file is encrypted; wrong or missing passphrase")
+
+type cookieFile struct {
+	Version int            `json:"version"`
+	Cookies []StoredCookie `json:"cookies,omitempty"`
+
+	// Set instead of Cookies when the file is encrypted
+	KDF        string `json:"kdf,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+// cookieCipher encrypts cookie files with a key derived from a passphrase
+type cookieCipher struct {
+	salt       []byte
+	iterations int
+	aead       cipher.AEAD
+}
+
+func newCookieCipher(passphrase string, salt []byte, iterations int) (*cookieCipher, error) {
+	if salt == nil {
+		salt = make([]byte, cookieSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive cookie key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cookieCipher{salt: salt, iterations: iterations, aead: aead}, nil
+}
+
+// decodeCookieFile parses a cookie file. Encrypted files need passphrase;
+// the returned cipher is reused for saving so the key is derived only once.
+// Files written before the versioned format map hosts to cookie lists.
+func decodeCookieFile(data []byte, passphrase string) ([]StoredCookie, *cookieCipher, error) {
+	var file cookieFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version == 0 {
+		var legacy map[string][]*http.Cookie
+		if legacyErr := json.Unmarshal(data, &legacy); legacyErr != nil {
+			return nil, nil, fmt.Errorf("unrecognized cookie file format")
+		}
+		var cookies []StoredCookie
+		for host, list := range legacy {
+			u := &url.URL{Scheme: "https", Host: host, Path: "/"}
+			for _, cookie := range list {
+				if stored, ok := storedFrom(u, cookie, time.Now()); ok {
+					cookies = append(cookies, stored)
+				}
+			}
+		}
+		return cookies, nil, nil
+	}
+	if file.Version > cookieFileVersion {
+		return nil, nil, fmt.Errorf("cookie file version %d is newer than supported", file.Version)
+	}
+	if file.Ciphertext == nil {
+		return file.Cookies, nil, nil
+	}
+
+	if file.KDF != "pbkdf2-sha256" || file.Iterations < 1 || len(file.Salt) == 0 {
+		return nil, nil, fmt.Errorf("unsupported cookie file encryption")
+	}
+	if passphrase == "" {
+		return nil, nil, ErrCookiePassphrase
+	}
+	c, err := newCookieCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := c.aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, nil, ErrCookiePassphrase
+	}
+	var cookies []StoredCookie
+	if err := json.Unmarshal(plain, &cookies); err != nil {
+		return nil, nil, fmt.Errorf("corrupt encrypted cookie file: %w", err)
+	}
+	return cookies, c, nil
+}
+
+// encodeCookieFile serializes cookies, encrypting them when c is set
+func encodeCookieFile(cookies []StoredCookie, c *cookieCipher) ([]byte, error) {
+	file := cookieFile{Version: cookieFileVersion}
+	if c == nil {
+		file.Cookies = cookies
+		return json.MarshalIndent(file, "", "  ")
+	}
+
+	plain, err := json.Marshal(cookies)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.KDF = "pbkdf2-sha256"
+	file.Iterations = c.iterations
+	file.Salt = c.salt
+	file.Nonce = nonce
+	file.Ciphertext = c.aead.Seal(nil, nonce, plain, nil)
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// writeFileAtomic replaces name with data readable only by the owner. The
+// data goes to a temporary file in the same directory that is renamed over
+// name, so a crash never leaves a partially written file behind.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op once renamed
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, name)
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieDomain(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		want         string
+		hostOnly, ok bool
+	}{
+		{"www.amazon.com", "", "www.amazon.com", true, true},
+		{"www.amazon.com", ".amazon.com", "amazon.com", false, true},
+		{"www.amazon.com", "WWW.Amazon.com", "www.amazon.com", false, true},
+		{"www.amazon.com", "example.org", "", false, false},
+		{"www.amazon.com", "mazon.com", "", false, false},
+		{"www.amazon.com", ".com", "", false, false},
+		{"www.amazon.co.uk", "co.uk", "", false, false},
+		{"www.amazon.co.uk", "amazon.co.uk", "amazon.co.uk", false, true},
+		{"co.uk", "co.uk", "co.uk", true, true}, // A public suffix may set cookies on itself only
+		{"127.0.0.1", "127.0.0.1", "127.0.0.1", true, true},
+		{"127.0.0.1", "0.0.1", "", false, false},
+		{"www.amazon.com", "..amazon.com", "", false, false},
+	}
+	for _, tt := range tests {
+		got, hostOnly, ok := cookieDomain(tt.host, tt.domain)
+		if ok != tt.ok || (ok && (got != tt.want || hostOnly != tt.hostOnly)) {
+			t.Errorf("cookieDomain(%q, %q) = %q, %t, %t, want %q, %t, %t",
+				tt.host, tt.domain, got, hostOnly, ok, tt.want, tt.hostOnly, tt.ok)
+		}
+	}
+}
+
+func TestPersistentJarRecordsAcceptedCookies(t *testing.T) {
+	jar, err := newPersistentJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.amazon.com/dp/B0DT7L98J1")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session-id", Value: "1", Domain: ".amazon.com", Path: "/"},
+		{Name: "host", Value: "2", Path: "/"},
+		{Name: "tld", Value: "3", Domain: ".com", Path: "/"},
+		{Name: "other", Value: "4", Domain: "example.org", Path: "/"},
+	})
+
+	var names []string
+	for _, cookie := range jar.snapshot(time.Now()) {
+		names = append(names, cookie.Domain+"/"+cookie.Name)
+	}
+	if got, want := strings.Join(names, " "), "amazon.com/session-id www.amazon.com/host"; got != want {
+		t.Fatalf("recorded %q, want %q", got, want)
+	}
+	if got := len(jar.Cookies(u)); got != 2 {
+		t.Fatalf("jar sends %d cookies, want 2", got)
+	}
+}
+
+func TestImportSkipsInvalidDomains(t *testing.T) {
+	const file = "# Netscape HTTP Cookie File\n" +
+		".amazon.co.uk\tTRUE\t/\tTRUE\t0\tsession-id\t1\n" +
+		".co.uk\tTRUE\t/\tFALSE\t0\ttracker\t2\n" +
+		"#HttpOnly_www.amazon.co.uk\tFALSE\t/\tTRUE\t0\tat-acbuk\t3\n" +
+		"..amazon.co.uk\tTRUE\t/\tFALSE\t0\tbroken\t4\n" +
+		".amazon.co.uk\tTRUE\t/\tFALSE\t1\told\t5\n"
+	cookies, err := ParseNetscapeCookies(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := Open(SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	imported, expired, rejected := session.ImportCookies(cookies)
+	if imported != 2 || expired != 1 || rejected != 2 {
+		t.Fatalf("imported %d, expired %d, rejected %d, want 2, 1, 2", imported, expired, rejected)
+	}
+	u, _ := url.Parse("https://www.amazon.co.uk/")
+	if got := len(session.Client.Jar.Cookies(u)); got != 2 {
+		t.Fatalf("jar sends %d cookies, want 2", got)
+	}
+	if got := len(session.Cookies()); got != 2 {
+		t.Fatalf("session records %d cookies, want 2", got)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+
+	"gpu-sniper/config"
+)
+
+// ErrBodyTooLarge is returned when a decoded body exceeds config.MaxResponseBytes
+var ErrBodyTooLarge = errors.New("response body exceeds the maximum allowed size")
+
+// limitReader reads at most limit bytes from r and fails with
+// ErrBodyTooLarge instead of EOF when there is more, so a streamed body that
+// is too large is not mistaken for a complete one
+type limitReader struct {
+	r        io.Reader // Limited to limit+1 bytes
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func newLimitReader(r io.Reader, limit int64) io.Reader {
+	return &limitReader{r: io.LimitReader(r, limit+1), limit: limit}
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, fmt.Errorf("%w (%d bytes)", ErrBodyTooLarge, l.limit)
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		l.exceeded = true
+		return n - int(l.read-l.limit), fmt.Errorf("%w (%d bytes)", ErrBodyTooLarge, l.limit)
+	}
+	return n, err
+}
+
+// ReadDecodedBody reads the whole response body, undoing any
+// Content-Encoding (gzip, deflate, br) and converting the declared charset
+// to UTF-8. The body is closed afterwards.
+func ReadDecodedBody(resp *http.Response) ([]byte, error) {
+	_, body, err := ReadBody(resp)
+	return body, err
+}
+
+// ReadBody reads the whole response body and returns both the bytes as
+// received and the decoded UTF-8 text. The body is closed afterwards.
+func ReadBody(resp *http.Response) (raw, decoded []byte, err error) {
+	defer resp.Body.Close()
+
+	limit := config.MaxResponseBytes
+	raw, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(raw)) > limit {
+		return nil, nil, fmt.Errorf("%w (%d bytes)", ErrBodyTooLarge, limit)
+	}
+
+	// The transport already undid gzip if it negotiated it itself
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if resp.Uncompressed {
+		contentEncoding = ""
+	}
+
+	decoded, err = DecodeBody(raw, contentEncoding, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, decoded, nil
+}
+
+// DecodeBody undoes contentEncoding on raw and converts the charset
+// declared by contentType (or the document itself) to UTF-8
+func DecodeBody(raw []byte, contentEncoding, contentType string) ([]byte, error) {
+	reader, err := decodeContent(bytes.NewReader(raw), contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit the decoded size so a compressed bomb can't exhaust memory
+	limit := config.MaxResponseBytes
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrBodyTooLarge, limit)
+	}
+
+	return toUTF8(body, contentType)
+}
+
+// decodeContent wraps r with decoders for each listed content coding,
+// undoing them in reverse order of application
+func decodeContent(r io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		switch coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gzip body: %w", err)
+			}
+			r = gz
+		case "deflate":
+			r = newDeflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+	}
+	return r, nil
+}
+
+// newDeflateReader handles both zlib-wrapped deflate (as the spec says) and
+// raw deflate streams (as some servers send)
+func newDeflateReader(r io.Reader) io.Reader {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(buffered); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(buffered)
+}
+
+// toUTF8 converts body to UTF-8 based on the Content-Type charset, a BOM or
+// a <meta charset> tag
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	if !strings.Contains(strings.ToLower(contentType), "html") && !strings.HasPrefix(strings.ToLower(contentType), "text/") {
+		return body, nil
+	}
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		return body, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s body to UTF-8: %w", name, err)
+	}
+	return decoded, nil
+}
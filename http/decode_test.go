@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"gpu-sniper/config"
+)
+
+const decodeSample = `<html><body><span id="add-to-cart-button">Add to Cart</span></body></html>`
+
+// encode applies one content coding to body
+func encode(t *testing.T, coding string, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	if _, err := w.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	sample := []byte(decodeSample)
+	tests := []struct {
+		name            string
+		raw             []byte
+		contentEncoding string
+		contentType     string
+		want            string
+	}{
+		{"identity", sample, "", "text/html", decodeSample},
+		{"gzip", encode(t, "gzip", sample), "gzip", "text/html", decodeSample},
+		{"x-gzip", encode(t, "gzip", sample), "x-gzip", "text/html", decodeSample},
+		{"zlib deflate", encode(t, "zlib", sample), "deflate", "text/html", decodeSample},
+		{"raw deflate", encode(t, "flate", sample), "deflate", "text/html", decodeSample},
+		{"brotli", encode(t, "br", sample), "br", "text/html", decodeSample},
+		{"gzip then brotli", encode(t, "br", encode(t, "gzip", sample)), "gzip, BR", "text/html", decodeSample},
+		{"latin-1 header", []byte("<p>Caf\xe9 cr\xe8me</p>"), "", "text/html; charset=ISO-8859-1", "<p>Café crème</p>"},
+		{"windows-1252 header", []byte("<p>\x801.999,00</p>"), "", "text/html; charset=windows-1252", "<p>€1.999,00</p>"},
+		{"shift_jis header", []byte("<p>\x83J\x81[\x83g\x82\xc9\x93\xfc\x82\xea\x82\xe9</p>"), "", "text/html; charset=Shift_JIS", "<p>カートに入れる</p>"},
+		{"meta charset", []byte("<html><head><meta charset=\"iso-8859-1\"></head><body>Pr\xe9commande</body></html>"), "", "text/html",
+			`<html><head><meta charset="iso-8859-1"></head><body>Précommande</body></html>`},
+		{"gzipped latin-1", encode(t, "gzip", []byte("<p>Caf\xe9</p>")), "gzip", "text/html; charset=latin1", "<p>Café</p>"},
+		{"json untouched", []byte("{\"name\":\"Caf\xe9\"}"), "", "application/json", "{\"name\":\"Caf\xe9\"}"},
+	}
+	for _, tt := range tests {
+		got, err := DecodeBody(tt.raw, tt.contentEncoding, tt.contentType)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: DecodeBody = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeBodyErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		raw             []byte
+		contentEncoding string
+		errText         string
+	}{
+		{"unsupported", []byte("x"), "compress", `unsupported content encoding "compress"`},
+		{"not gzip", []byte("plain text"), "gzip", "invalid gzip body"},
+		{"truncated brotli", encode(t, "br", []byte(decodeSample))[:5], "br", "failed to decode response body"},
+	}
+	for _, tt := range tests {
+		_, err := DecodeBody(tt.raw, tt.contentEncoding, "text/html")
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("%s: DecodeBody = %v, want an error containing %q", tt.name, err, tt.errText)
+		}
+	}
+}
+
+func TestBodySizeLimit(t *testing.T) {
+	saved := config.MaxResponseBytes
+	config.MaxResponseBytes = 1024
+	defer func() { config.MaxResponseBytes = saved }()
+
+	atLimit := bytes.Repeat([]byte("a"), 1024)
+	overLimit := bytes.Repeat([]byte("a"), 1025)
+	tests := []struct {
+		name            string
+		raw             []byte
+		contentEncoding string
+		tooLarge        bool
+	}{
+		{"at the limit", atLimit, "", false},
+		{"just over", overLimit, "", true},
+		{"gzip at the limit", encode(t, "gzip", atLimit), "gzip", false},
+		{"gzip just over", encode(t, "gzip", overLimit), "gzip", true}, // Small on the wire, too large decoded
+		{"brotli just over", encode(t, "br", overLimit), "br", true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Encoding": {tt.contentEncoding}, "Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(bytes.NewReader(tt.raw)),
+		}
+		raw, decoded, err := ReadBody(resp)
+		if got := errors.Is(err, ErrBodyTooLarge); got != tt.tooLarge {
+			t.Errorf("%s: ReadBody = %v, want too large %t", tt.name, err, tt.tooLarge)
+			continue
+		}
+		if !tt.tooLarge && (!bytes.Equal(raw, tt.raw) || len(decoded) != 1024) {
+			t.Errorf("%s: ReadBody returned %d raw and %d decoded bytes", tt.name, len(raw), len(decoded))
+		}
+	}
+}
+
+func TestReadBodyHonorsTransportDecompression(t *testing.T) {
+	// The transport already gunzipped the body but left the header
+	resp := &http.Response{
+		Header:       http.Header{"Content-Encoding": {"gzip"}, "Content-Type": {"text/html"}},
+		Body:         io.NopCloser(strings.NewReader(decodeSample)),
+		Uncompressed: true,
+	}
+	_, decoded, err := ReadBody(resp)
+	if err != nil || string(decoded) != decodeSample {
+		t.Errorf("ReadBody = %q, %v, want the body as is", decoded, err)
+	}
+}
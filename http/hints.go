@@ -0,0 +1,118 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerHints are scheduling hints extracted from a response
+type ServerHints struct {
+	RetryAfter  time.Duration // Delay requested via the Retry-After header
+	Maintenance bool          // Server reported 503 Service Unavailable
+	MaxAge      time.Duration // How long the response stays fresh according to cache headers
+}
+
+// ParseRetryAfter parses a Retry-After value given either as delay seconds
+// or as an HTTP-date. Dates in the past yield a zero delay.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return secondsDuration(seconds), true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if date.Before(now) {
+		return 0, true
+	}
+	return date.Sub(now), true
+}
+
+// ParseServerHints extracts Retry-After, maintenance and cache freshness
+// hints from the response headers
+func ParseServerHints(resp *http.Response, now time.Time) ServerHints {
+	var hints ServerHints
+	if resp == nil {
+		return hints
+	}
+
+	if delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		hints.RetryAfter = delay
+	}
+	hints.Maintenance = resp.StatusCode == http.StatusServiceUnavailable
+	hints.MaxAge = freshness(resp.Header, now)
+
+	return hints
+}
+
+// freshness computes the remaining freshness lifetime from Cache-Control,
+// Age and Expires headers
+func freshness(header http.Header, now time.Time) time.Duration {
+	var maxAge time.Duration
+	hasMaxAge := false
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store", "must-revalidate":
+			return 0
+		case "max-age", "s-maxage":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil || seconds < 0 {
+				continue
+			}
+			// s-maxage takes precedence over max-age
+			if !hasMaxAge || strings.ToLower(name) == "s-maxage" {
+				maxAge = secondsDuration(seconds)
+				hasMaxAge = true
+			}
+		}
+	}
+
+	if hasMaxAge {
+		if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+			maxAge -= secondsDuration(age)
+		}
+		if maxAge < 0 {
+			return 0
+		}
+		return maxAge
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		// Prefer the server's clock when it sent one
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+		if expiresAt.After(now) {
+			return expiresAt.Sub(now)
+		}
+	}
+
+	return 0
+}
+
+// secondsDuration converts a header's delta-seconds to a duration, saturating
+// instead of overflowing for absurdly large values
+func secondsDuration(seconds int) time.Duration {
+	if int64(seconds) > math.MaxInt64/int64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds) * time.Second
+}
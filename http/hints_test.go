@@ -0,0 +1,70 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var hintsNow = time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Fri, 06 Mar 2026 12:05:00 GMT", 5 * time.Minute, true},
+		{"Friday, 06-Mar-26 12:00:30 GMT", 30 * time.Second, true}, // RFC 850
+		{"Fri Mar  6 12:01:00 2026", time.Minute, true},            // ANSI C
+		{"Fri, 06 Mar 2026 11:00:00 GMT", 0, true},                 // In the past
+		{"99999999999999999", math.MaxInt64, true},
+		{"-5", 0, false},
+		{"1.5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.value, hintsNow)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, %t, want %v, %t", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseServerHints(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   ServerHints
+	}{
+		{"none", 200, http.Header{}, ServerHints{}},
+		{"rate limited", 429, http.Header{"Retry-After": {"30"}}, ServerHints{RetryAfter: 30 * time.Second}},
+		{"maintenance", 503, http.Header{}, ServerHints{Maintenance: true}},
+		{"maintenance until", 503, http.Header{"Retry-After": {"Fri, 06 Mar 2026 13:00:00 GMT"}}, ServerHints{RetryAfter: time.Hour, Maintenance: true}},
+		{"garbage retry-after", 429, http.Header{"Retry-After": {"later"}}, ServerHints{}},
+		{"max-age", 200, http.Header{"Cache-Control": {"public, max-age=300"}}, ServerHints{MaxAge: 5 * time.Minute}},
+		{"s-maxage wins", 200, http.Header{"Cache-Control": {`s-maxage="60", max-age=300`}}, ServerHints{MaxAge: time.Minute}},
+		{"minus age", 200, http.Header{"Cache-Control": {"max-age=300"}, "Age": {"120"}}, ServerHints{MaxAge: 3 * time.Minute}},
+		{"stale", 200, http.Header{"Cache-Control": {"max-age=60"}, "Age": {"120"}}, ServerHints{}},
+		{"no-cache", 200, http.Header{"Cache-Control": {"max-age=300, no-cache"}}, ServerHints{}},
+		{"garbage max-age", 200, http.Header{"Cache-Control": {"max-age=abc"}}, ServerHints{}},
+		{"expires", 200, http.Header{"Expires": {"Fri, 06 Mar 2026 12:10:00 GMT"}}, ServerHints{MaxAge: 10 * time.Minute}},
+		{"expires by server clock", 200, http.Header{"Expires": {"Fri, 06 Mar 2026 12:10:00 GMT"}, "Date": {"Fri, 06 Mar 2026 12:08:00 GMT"}}, ServerHints{MaxAge: 2 * time.Minute}},
+		{"expired", 200, http.Header{"Expires": {"Fri, 06 Mar 2026 11:00:00 GMT"}}, ServerHints{}},
+		{"garbage expires", 200, http.Header{"Expires": {"0"}}, ServerHints{}},
+	}
+	for _, tt := range tests {
+		got := ParseServerHints(&http.Response{StatusCode: tt.status, Header: tt.header}, hintsNow)
+		if got != tt.want {
+			t.Errorf("%s: ParseServerHints = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if got := ParseServerHints(nil, hintsNow); got != (ServerHints{}) {
+		t.Errorf("ParseServerHints(nil) = %+v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in files exported by browsers and curl
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ParseNetscapeCookies reads cookies in the Netscape cookies.txt format
+// exported by browser extensions, curl and wget. Domains are only checked
+// when the cookies are imported into a session, like saved cookies.
+func ParseNetscapeCookies(r io.Reader) ([]StoredCookie, error) {
+	var cookies []StoredCookie
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		if httpOnly {
+			text = strings.TrimPrefix(text, httpOnlyPrefix)
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "") // Empty value
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", line, fields[4])
+		}
+
+		domain := fields[0]
+		cookie := StoredCookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.ToLower(strings.TrimPrefix(domain, ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		if cookie.Name == "" || cookie.Domain == "" {
+			return nil, fmt.Errorf("line %d: missing cookie name or domain", line)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+// WriteNetscapeCookies writes cookies in the Netscape cookies.txt format
+func WriteNetscapeCookies(w io.Writer, cookies []StoredCookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(bw, "# Exported by gpu-sniper. This file contains session secrets; keep it private.")
+	fmt.Fprintln(bw)
+	for _, cookie := range cookies {
+		domain := cookie.Domain
+		if !cookie.HostOnly {
+			domain = "." + domain
+		}
+		if cookie.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!cookie.HostOnly), cookie.Path, netscapeBool(cookie.Secure),
+			expires, cookie.Name, cookie.Value)
+	}
+	return bw.Flush()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,269 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+
+	"gpu-sniper/config"
+)
+
+// Page is a fetched response shared by every consumer. Pages returned by
+// FetchPage are read completely up front; pages returned by OpenPage are
+// read on demand so a streaming consumer can stop early. The decoded text,
+// its lowercase form and the DOM are computed lazily and cached.
+type Page struct {
+	URL        string        // Requested URL
+	StatusCode int           // HTTP status code
+	Header     http.Header   // Response headers
+	Raw        []byte        // Body as received on the wire (nil for streamed pages)
+	FetchedAt  time.Time     // When the request was sent
+	Latency    time.Duration // Time until the response headers arrived
+	Duration   time.Duration // Time until the body was fully read
+
+	mu      sync.Mutex
+	buf     bytes.Buffer // Decoded bytes read so far
+	stream  io.Reader    // Remaining decoded body, nil once fully read
+	closer  io.Closer    // Network body of a streamed page
+	partial bool         // Closed before the whole body was read
+	loadErr error
+
+	textOnce  sync.Once
+	text      string
+	lowerOnce sync.Once
+	lower     string
+	docOnce   sync.Once
+	doc       *goquery.Document
+	docErr    error
+}
+
+// FetchPage sends req with the session's client and reads the whole decoded body
+func (s *Session) FetchPage(req *http.Request) (*Page, error) {
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("network error: failed to fetch page. Please check your internet connection: %w", err)
+	}
+	latency := time.Since(start)
+
+	page, err := PageFromResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	page.FetchedAt = start
+	page.Latency = latency
+	page.Duration = time.Since(start)
+	return page, nil
+}
+
+// OpenPage sends req with the session's client and returns as soon as the headers
+// arrive. The body is decoded while it is read; call Close when done so an
+// unread remainder is not downloaded. Reading past config.MaxResponseBytes
+// fails with ErrBodyTooLarge.
+func (s *Session) OpenPage(req *http.Request) (*Page, error) {
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("network error: failed to fetch page. Please check your internet connection: %w", err)
+	}
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if resp.Uncompressed {
+		contentEncoding = ""
+	}
+	reader, err := decodeContent(newLimitReader(resp.Body, config.MaxResponseBytes), contentEncoding)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if utf8, err := charset.NewReader(reader, resp.Header.Get("Content-Type")); err == nil {
+		reader = utf8
+	}
+	reader = newLimitReader(reader, config.MaxResponseBytes)
+
+	page := newPage(resp.Request.URL.String(), resp.StatusCode, resp.Header)
+	page.FetchedAt = start
+	page.Latency = time.Since(start)
+	page.stream = reader
+	page.closer = resp.Body
+	return page, nil
+}
+
+// PageFromResponse reads and decodes resp into a Page, closing its body
+func PageFromResponse(resp *http.Response) (*Page, error) {
+	raw, body, err := ReadBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	pageURL := ""
+	if resp.Request != nil {
+		pageURL = resp.Request.URL.String()
+	}
+	page := NewPage(pageURL, resp.StatusCode, resp.Header, body)
+	page.Raw = raw
+	return page, nil
+}
+
+// NewPage builds a page from an already decoded body, e.g. a saved fixture
+func NewPage(url string, statusCode int, header http.Header, body []byte) *Page {
+	page := newPage(url, statusCode, header)
+	page.Raw = body
+	page.buf.Write(body)
+	return page
+}
+
+func newPage(url string, statusCode int, header http.Header) *Page {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &Page{
+		URL:        url,
+		StatusCode: statusCode,
+		Header:     header,
+		FetchedAt:  time.Now(),
+	}
+}
+
+// Stream returns a reader over the decoded body that starts after the bytes
+// already read. Everything read through it stays available to Body.
+func (p *Page) Stream() io.Reader {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream == nil {
+		return bytes.NewReader(nil)
+	}
+	return &pageStream{page: p, r: io.TeeReader(p.stream, &p.buf)}
+}
+
+// pageStream marks the page as fully read once its body reaches EOF
+type pageStream struct {
+	page *Page
+	r    io.Reader
+}
+
+func (s *pageStream) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if err == io.EOF {
+		s.page.mu.Lock()
+		s.page.stream = nil
+		s.page.mu.Unlock()
+	}
+	return n, err
+}
+
+// Buffered returns the decoded bytes read so far without reading further
+func (p *Page) Buffered() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.Bytes()
+}
+
+// Body returns the whole decoded body, reading any remainder first
+func (p *Page) Body() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.load()
+	return p.buf.Bytes()
+}
+
+// Err returns the error encountered while reading the remainder of the body
+func (p *Page) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadErr
+}
+
+// Size returns the number of decoded bytes read so far
+func (p *Page) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.Len()
+}
+
+// Close releases the network body of a streamed page. Reading stops here,
+// so Body only returns what was read before.
+func (p *Page) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != nil {
+		p.partial = true
+	}
+	p.stream = nil
+	if p.closer == nil {
+		return nil
+	}
+	err := p.closer.Close()
+	p.closer = nil
+	if p.Duration == 0 {
+		p.Duration = time.Since(p.FetchedAt)
+	}
+	return err
+}
+
+// Partial reports whether the page was closed before its whole body was read
+func (p *Page) Partial() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.partial
+}
+
+// load reads the rest of a streamed body; p.mu must be held
+func (p *Page) load() {
+	if p.stream == nil {
+		return
+	}
+	if _, err := p.buf.ReadFrom(p.stream); err != nil {
+		p.loadErr = fmt.Errorf("failed to read response: %w", err)
+	}
+	p.stream = nil
+	if p.closer != nil {
+		p.closer.Close()
+		p.closer = nil
+	}
+	p.Duration = time.Since(p.FetchedAt)
+}
+
+// Text returns the decoded body as a string
+func (p *Page) Text() string {
+	p.textOnce.Do(func() {
+		p.text = string(p.Body())
+	})
+	return p.text
+}
+
+// LowerText returns the decoded body in lower case for case-insensitive matching
+func (p *Page) LowerText() string {
+	p.lowerOnce.Do(func() {
+		p.lower = strings.ToLower(p.Text())
+	})
+	return p.lower
+}
+
+// Document returns the parsed DOM, parsing the body on first use
+func (p *Page) Document() (*goquery.Document, error) {
+	p.docOnce.Do(func() {
+		body := p.Body()
+		if err := p.Err(); err != nil {
+			p.docErr = err
+			return
+		}
+		p.doc, p.docErr = goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if p.docErr != nil {
+			p.docErr = fmt.Errorf("error parsing HTML: %w", p.docErr)
+		}
+	})
+	return p.doc, p.docErr
+}
+
+// Hints returns the scheduling hints sent with the page
+func (p *Page) Hints(now time.Time) ServerHints {
+	return ParseServerHints(&http.Response{StatusCode: p.StatusCode, Header: p.Header}, now)
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gpu-sniper/config"
+)
+
+func TestOpenPageLimit(t *testing.T) {
+	saved := config.MaxResponseBytes
+	config.MaxResponseBytes = 1024
+	defer func() { config.MaxResponseBytes = saved }()
+
+	var zipped bytes.Buffer
+	gz := gzip.NewWriter(&zipped)
+	gz.Write(bytes.Repeat([]byte("a"), 4096)) // Compresses to far less than the limit
+	gz.Close()
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		tooLarge bool
+	}{
+		{"at the limit", bytes.Repeat([]byte("a"), 1024), "", false},
+		{"one byte over", bytes.Repeat([]byte("a"), 1025), "", true},
+		{"decoded over", zipped.Bytes(), "gzip", true},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Encoding", tt.encoding)
+			w.Write(tt.body)
+		}))
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		page, err := (&Session{Client: server.Client()}).OpenPage(req)
+		if err != nil {
+			t.Fatalf("%s: OpenPage: %v", tt.name, err)
+		}
+
+		// A stream reader sees the error instead of EOF
+		read, err := io.ReadAll(page.Stream())
+		if got := errors.Is(err, ErrBodyTooLarge); got != tt.tooLarge || (!tt.tooLarge && err != nil) {
+			t.Errorf("%s: reading the stream = %v, want too large %t", tt.name, err, tt.tooLarge)
+		}
+		if int64(len(read)) > config.MaxResponseBytes {
+			t.Errorf("%s: read %d bytes past the limit", tt.name, len(read))
+		}
+		page.Close()
+		server.Close()
+	}
+
+	// Body reports the error through Err
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), 2048))
+	}))
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	page, err := (&Session{Client: server.Client()}).OpenPage(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Close()
+	page.Body()
+	if !errors.Is(page.Err(), ErrBodyTooLarge) {
+		t.Errorf("Err = %v, want ErrBodyTooLarge", page.Err())
+	}
+	if _, err := page.Document(); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Document = %v, want ErrBodyTooLarge", err)
+	}
+}
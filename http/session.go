@@ -0,0 +1,195 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gpu-sniper/ui"
+)
+
+// SessionOptions configures a Session
+type SessionOptions struct {
+	CookieFile   string        // Where cookies are persisted; empty keeps them in memory only
+	SaveInterval time.Duration // How often a started session saves cookies (default 30s)
+	UserAgent    string        // User agent for every request; picked at random when empty
+	Timeout      time.Duration // Per-request timeout (default 10s)
+	Passphrase   string        // Encrypts the cookie file when set
+}
+
+// Session is a browsing session with the retailer: one HTTP client, one
+// cookie jar and one user agent. Opening a session only loads its cookies;
+// the periodic cookie saver runs between Start and Close.
+type Session struct {
+	Client    *http.Client
+	UserAgent string
+
+	jar    *persistentJar
+	opts   SessionOptions
+	cipher *cookieCipher // Encrypts the cookie file, nil when stored in plain text
+	fileMu sync.Mutex    // Serializes cookie file writes
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Open creates a session and loads any cookies saved at opts.CookieFile
+func Open(opts SessionOptions) (*Session, error) {
+	if opts.SaveInterval <= 0 {
+		opts.SaveInterval = 30 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = userAgents[rand.Intn(len(userAgents))]
+	}
+
+	jar, err := newPersistentJar()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			Jar: jar,
+		},
+		UserAgent: opts.UserAgent,
+		jar:       jar,
+		opts:      opts,
+	}
+	if err := s.loadCookies(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start begins saving cookies periodically until Close
+func (s *Session) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed || s.opts.CookieFile == "" {
+		return
+	}
+	s.started = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.periodicCookieSave()
+}
+
+// Close stops the cookie saver and saves the cookies one last time
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	started := s.started
+	s.mu.Unlock()
+
+	if started {
+		close(s.stop)
+		<-s.done
+	}
+	s.Client.CloseIdleConnections()
+	return s.SaveCookies()
+}
+
+// Periodically save cookies until the session is closed
+func (s *Session) periodicCookieSave() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.SaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.SaveCookies(); err != nil {
+				ui.LogError("%v", err)
+			}
+		}
+	}
+}
+
+// Load cookies from file and set them in the cookie jar
+func (s *Session) loadCookies() error {
+	if s.opts.CookieFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.opts.CookieFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading cookie file: %w", err)
+	}
+
+	var cookies []StoredCookie
+	if len(data) > 0 {
+		cookies, s.cipher, err = decodeCookieFile(data, s.opts.Passphrase)
+		if errors.Is(err, ErrCookiePassphrase) {
+			return fmt.Errorf("%s: %w", s.opts.CookieFile, err)
+		}
+		if err != nil {
+			ui.LogWarning("Ignoring unreadable cookie file %s: %v", s.opts.CookieFile, err)
+			cookies = nil
+		}
+	}
+
+	// Encrypt from now on if a passphrase is set, even if the file was plain
+	if s.cipher == nil && s.opts.Passphrase != "" {
+		if s.cipher, err = newCookieCipher(s.opts.Passphrase, nil, cookieKDFRounds); err != nil {
+			return err
+		}
+	}
+
+	loaded, pruned, rejected := s.jar.restore(cookies, time.Now())
+	if loaded > 0 || pruned > 0 {
+		ui.LogInfo("Loaded %d cookie(s) from %s (%d expired)", loaded, s.opts.CookieFile, pruned)
+	}
+	if rejected > 0 {
+		ui.LogWarning("Dropped %d cookie(s) from %s with a domain their host could not set", rejected, s.opts.CookieFile)
+	}
+	return nil
+}
+
+// SaveCookies writes every unexpired cookie to the cookie file right away
+func (s *Session) SaveCookies() error {
+	if s.opts.CookieFile == "" {
+		return nil
+	}
+	data, err := encodeCookieFile(s.jar.snapshot(time.Now()), s.cipher)
+	if err != nil {
+		return fmt.Errorf("error encoding cookies: %w", err)
+	}
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if err := writeFileAtomic(s.opts.CookieFile, data); err != nil {
+		return fmt.Errorf("error writing cookie file: %w", err)
+	}
+	return nil
+}
+
+// Cookies returns every unexpired cookie in the session
+func (s *Session) Cookies() []StoredCookie {
+	return s.jar.snapshot(time.Now())
+}
+
+// ImportCookies adds cookies to the session, replacing cookies with the
+// same domain, path and name. Expired cookies are skipped, and so are
+// cookies for a public suffix or an otherwise invalid domain.
+func (s *Session) ImportCookies(cookies []StoredCookie) (imported, expired, rejected int) {
+	return s.jar.restore(cookies, time.Now())
+}
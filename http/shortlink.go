@@ -0,0 +1,54 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gpu-sniper/config"
+)
+
+// maxShortLinkHops bounds the redirects followed when expanding a short link
+const maxShortLinkHops = 5
+
+// ExpandShortLink follows the redirects of a short link such as amzn.to/…
+// until they reach a retailer page and returns that page's URL. The page
+// itself is not fetched.
+func ExpandShortLink(rawURL string) (string, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	current := rawURL
+	for hop := 0; hop < maxShortLinkHops; hop++ {
+		req, err := http.NewRequest("GET", current, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", userAgents[0])
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("network error: %w", err)
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return current, nil
+		}
+		next, err := resp.Request.URL.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect %q: %w", location, err)
+		}
+		current = next.String()
+		if _, ok := config.MarketplaceFor(next.Hostname()); ok {
+			return current, nil
+		}
+	}
+	return "", fmt.Errorf("too many redirects")
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpandShortLink(t *testing.T) {
+	const product = "https://www.amazon.com/NVIDIA-GeForce-RTX-5090/dp/B0DT7L98J1?tag=xyz"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3AbCdEf":
+			http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+		case "/hop":
+			http.Redirect(w, r, product, http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Write([]byte("<html>not a redirect</html>"))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		want    string
+		errText string
+	}{
+		{"/3AbCdEf", product, ""},
+		{"/landing", server.URL + "/landing", ""},
+		{"/loop", "", "too many redirects"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandShortLink(server.URL + tt.path)
+		if tt.errText != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("ExpandShortLink(%s) = %q, %v, want an error containing %q", tt.path, got, err, tt.errText)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandShortLink(%s) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
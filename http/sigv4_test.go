@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Credentials, region and date of the AWS Signature Version 4 test suite
+var (
+	testCreds   = Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	testSignsAt = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+// authField returns one field of a SigV4 Authorization header
+func authField(auth, name string) string {
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestSignV4TestSuite(t *testing.T) {
+	tests := []struct {
+		name          string
+		method, url   string
+		header        http.Header
+		body          string
+		service       string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name: "get-vanilla", method: "GET", url: "https://example.amazonaws.com/", service: "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key-case", method: "GET", url: "https://example.amazonaws.com/?Param2=value2&Param1=value1", service: "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name: "post-vanilla", method: "POST", url: "https://example.amazonaws.com/", service: "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name: "post-x-www-form-urlencoded", method: "POST", url: "https://example.amazonaws.com/", service: "service",
+			header:        http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:          "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name: "get-header-value-trim", method: "GET", url: "https://example.amazonaws.com/", service: "service",
+			header:        http.Header{"My-Header1": {" value1"}, "My-Header2": {`"a   b   c"`}},
+			signedHeaders: "host;my-header1;my-header2;x-amz-date",
+			signature:     "acc3ed3afb60bb290fc8d2dd0098b9911fcaa05412b367055dee359757a9c736",
+		},
+		{
+			// The IAM ListUsers example of the Signature Version 4 documentation
+			name: "iam-list-users", method: "GET", url: "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", service: "iam",
+			header:        http.Header{"Content-Type": {"application/x-www-form-urlencoded; charset=utf-8"}},
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			SignV4(req, []byte(tt.body), testCreds, "us-east-1", tt.service, testSignsAt)
+
+			auth := req.Header.Get("Authorization")
+			if got := authField(auth, "Credential"); got != "AKIDEXAMPLE/20150830/us-east-1/"+tt.service+"/aws4_request" {
+				t.Errorf("Credential = %q", got)
+			}
+			if got := authField(auth, "SignedHeaders"); got != tt.signedHeaders {
+				t.Errorf("SignedHeaders = %q, want %q", got, tt.signedHeaders)
+			}
+			if got := authField(auth, "Signature"); got != tt.signature {
+				t.Errorf("Signature = %q, want %q", got, tt.signature)
+			}
+		})
+	}
+}
+
+func TestVerifyV4(t *testing.T) {
+	body := []byte(`{"ItemIds":["B0DT7L98J1"],"ItemIdType":"ASIN"}`)
+	signedAt := time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)
+	sign := func() *http.Request {
+		req, err := http.NewRequest("POST", "https://webservices.amazon.com/paapi5/getitems", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("X-Amz-Target", "com.amazon.paapi5.v1.ProductAdvertisingAPIv1.GetItems")
+		SignV4(req, body, testCreds, "us-east-1", "ProductAdvertisingAPI", signedAt)
+		return req
+	}
+	replaceAuth := func(req *http.Request, old, new string) {
+		req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), old, new, 1))
+	}
+
+	tests := []struct {
+		name    string
+		tamper  func(req *http.Request) // Changes the signed request, nil for none
+		body    []byte
+		now     time.Time
+		errText string // Part of the error, empty for none
+	}{
+		{"round trip", nil, body, signedAt.Add(time.Minute), ""},
+		{"clock behind", nil, body, signedAt.Add(-14 * time.Minute), ""},
+		{"changed body", nil, []byte(`{"ItemIds":["B0DT7L98J2"],"ItemIdType":"ASIN"}`), signedAt, "does not match"},
+		{"changed header", func(req *http.Request) { req.Header.Set("X-Amz-Target", "GetVariations") }, body, signedAt, "does not match"},
+		{"wrong secret", func(req *http.Request) {
+			SignV4(req, body, Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: "other"}, "us-east-1", "ProductAdvertisingAPI", signedAt)
+		}, body, signedAt, "does not match"},
+		{"stale", nil, body, signedAt.Add(16 * time.Minute), "more than 15m0s"},
+		{"from the future", nil, body, signedAt.Add(-16 * time.Minute), "more than 15m0s"},
+		{"date outside the scope", func(req *http.Request) { req.Header.Set("X-Amz-Date", "20260307T000000Z") }, body, signedAt, "credential scope"},
+		{"unsigned date", func(req *http.Request) {
+			replaceAuth(req, ";x-amz-date", "")
+		}, body, signedAt, "host and x-amz-date"},
+		{"unsigned host", func(req *http.Request) {
+			replaceAuth(req, "host;", "")
+		}, body, signedAt, "host and x-amz-date"},
+		{"no authorization", func(req *http.Request) { req.Header.Del("Authorization") }, body, signedAt, "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := sign()
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+			accessKey, err := VerifyV4(req, tt.body, testCreds.SecretKey, tt.now)
+			if tt.errText == "" {
+				if err != nil || accessKey != testCreds.AccessKey {
+					t.Fatalf("VerifyV4 = %q, %v, want %q", accessKey, err, testCreds.AccessKey)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("VerifyV4 = %v, want an error containing %q", err, tt.errText)
+			}
+		})
+	}
+}
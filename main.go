@@ -2,20 +2,86 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata" // Embed time zones for per-product schedules
 
 	"gpu-sniper/alerts"
 	"gpu-sniper/config"
+	"gpu-sniper/history"
+	httpClient "gpu-sniper/http"
 	"gpu-sniper/stock"
 	"gpu-sniper/ui"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run monitors until a shutdown signal and returns the process exit code
+func run() int {
+	configPath := flag.String("config", config.ConfigFile, "path to the JSON configuration file")
+	productURL := flag.String("url", "", "monitor only the product at this link (any Amazon product URL)")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	// API keys come from the environment so they stay out of the config file
+	if key := os.Getenv(config.BestBuyAPIKeyEnv); key != "" {
+		config.BestBuyAPIKey = key
+	}
+	config.PAAPIAccessKey = os.Getenv(config.PAAPIAccessKeyEnv)
+	config.PAAPISecretKey = os.Getenv(config.PAAPISecretKeyEnv)
+
+	// Product links may be short links that redirect to the product page
+	config.ResolveShortLink = httpClient.ExpandShortLink
+
+	// Load products and schedules from the configuration file, if present
+	if err := config.Load(*configPath); err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	if err := stock.CompileCustomSources(); err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	if *productURL != "" {
+		if err := config.UseProductURL(*productURL); err != nil {
+			ui.LogError("%v", err)
+			return exitStartup
+		}
+	}
+	if err := stock.LoadScripts(); err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+
+	// Run a command instead of monitoring if one was given
+	if flag.NArg() > 0 {
+		return runCommand(flag.Args())
+	}
+
+	// Open the retailer session with the cookies saved by the last run
+	session, err := openSession()
+	if err != nil {
+		ui.LogError("%v", err)
+		return exitStartup
+	}
+	stock.Session = session
+
+	// Every check result is appended to the history file
+	store, err := history.Open(config.HistoryFile)
+	if err != nil {
+		ui.LogError("%v", err)
+		session.Close()
+		return exitStartup
+	}
+
 	// Display application header
 	ui.PrintHeader()
 	
@@ -26,61 +92,169 @@ func main() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	// Use context for cancellation of countdown goroutines
+	// The context is cancelled on shutdown; every fetch, retry backoff and
+	// countdown watches it
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	
-	// Add WaitGroup to track goroutines
-	var wg sync.WaitGroup
-
-	// Track current progress
-	var currentTracker *ui.ProgressTracker
 
 	// Handle shutdown signals
 	go func() {
 		<-signalChan
 		fmt.Println("\nShutting down gracefully...")
-		cancel() // Cancel the context
-		// Wait for all goroutines to finish
-		wg.Wait()
-		os.Exit(0)
+		cancel() // Abort in-flight checks and backoffs
+		
+		// A second signal skips the graceful shutdown
+		<-signalChan
+		fmt.Println("\nForcing exit")
+		os.Exit(exitForced)
 	}()
 
-	// Run initial check
-	if stock.CheckStock() {
-		alerts.TriggerPurchase()
-	}
+	// Create channel for control flow; buffered so a countdown never blocks
+	done := make(chan bool, 1)
+	nextCheck := make(map[string]time.Time)
+	dedupe := alerts.NewDeduper(config.Dedupe)
+	session.Start()
+	summary := ui.SessionSummary{Started: time.Now()}
 
-	// Create channel for control flow
-	done := make(chan bool)
-	
-	// Start first countdown with context
-	wg.Add(1)
-	go func() {
-		currentTracker = ui.Countdown(ctx, config.PollingInterval, done)
-		// Update the global tracker in the stock package
-		stock.CurrentProgressTracker = currentTracker
-		wg.Done()
-	}()
-	
-	for range done {
-		// Clear any residual progress bar
-		fmt.Printf("\r\033[K")
+	for ctx.Err() == nil {
+		// Check every product whose schedule says it is due; on the first
+		// pass every product is due immediately
+		wait := checkDueProducts(ctx, nextCheck, dedupe, store, &summary)
+		if ctx.Err() != nil {
+			break
+		}
 		
-		// Run the check and trigger purchase if in stock
-		if stock.CheckStock() {
-			alerts.TriggerPurchase()
+		// Start a countdown until the next product is due
+		stock.SetProgressTracker(ui.Countdown(ctx, wait, done))
+		
+		select {
+		case <-ctx.Done():
+		case <-done:
+			// Clear any residual progress bar
+			fmt.Printf("\r\033[K")
+		}
+	}
+
+	// The loop only exits between checks, so nothing is in flight anymore
+	stock.SetProgressTracker(nil)
+	fmt.Printf("\r\033[K")
+	summary.Cooldowns = cooldownSummary(stock.GetStatus())
+	return shutdown(session, store, summary)
+}
+
+// cooldownSummary lists the hosts of status that are still cooling down
+func cooldownSummary(status stock.Status) []ui.HostCooldown {
+	var cooling []ui.HostCooldown
+	for host, c := range status.Cooldowns {
+		cooling = append(cooling, ui.HostCooldown{Host: host, Until: c.Until, Strikes: c.Strikes})
+	}
+	sort.Slice(cooling, func(i, j int) bool { return cooling[i].Host < cooling[j].Host })
+	return cooling
+}
+
+// checkDueProducts checks every product that is due, schedules its next
+// check, records the results, and returns the time until the next product
+// is due
+func checkDueProducts(ctx context.Context, nextCheck map[string]time.Time, dedupe *alerts.Deduper, store *history.Store, summary *ui.SessionSummary) time.Duration {
+	for _, product := range config.Products {
+		if ctx.Err() != nil {
+			return 0
 		}
+		if time.Now().Before(nextCheck[product.Key()]) {
+			continue
+		}
+
+		// Run the check and trigger purchase if an alert rule matches
+		result := stock.CheckStock(ctx, product)
+		rule, alerted := "", false
+		if result.Outcome == stock.OutcomeSuccess {
+			if matched, ok := product.MatchAlertRule(result.Facts(product)); ok {
+				rule = matched.Name
+				alerted = dedupe.Allow(product.Key(), rule, result.CheckedAt)
+				if alerted {
+					alerts.TriggerPurchase(alerts.Alert{
+						Product:  product,
+						Location: result.Location,
+						Price:    result.Price,
+						Currency: result.Currency,
+						CartURL:  result.CartURL,
+						Seller:   result.Seller,
+						Rule:     rule,
+					})
+				} else {
+					ui.LogInfo("Rule %q still matches, alert suppressed", rule)
+				}
+			} else {
+				dedupe.Reset(product.Key())
+				if result.InStock {
+					ui.LogInfo("In stock, but no alert rule matched")
+				}
+			}
+		}
+		recordResult(store, summary, product, result, rule, alerted)
 		fmt.Println(strings.Repeat("─", 50))
-		
-		// Start a new countdown cycle with jitter using the same context
-		nextInterval := stock.GetNextPollingInterval()
-		wg.Add(1)
-		go func() {
-			currentTracker = ui.Countdown(ctx, nextInterval, done)
-			// Update the global tracker in the stock package
-			stock.CurrentProgressTracker = currentTracker
-			wg.Done()
-		}()
+
+		// Schedule the next check with jitter
+		nextCheck[product.Key()] = time.Now().Add(stock.GetNextPollingInterval(product))
+	}
+
+	var earliest time.Time
+	for _, product := range config.Products {
+		if due := nextCheck[product.Key()]; earliest.IsZero() || due.Before(earliest) {
+			earliest = due
+		}
+	}
+	if wait := time.Until(earliest); wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
+
+// recordResult appends a check result to the history and the session totals.
+// rule is the alert rule that matched, alerted whether it notified.
+func recordResult(store *history.Store, summary *ui.SessionSummary, product config.Product, result stock.Result, rule string, alerted bool) {
+	switch result.Outcome {
+	case stock.OutcomeCancelled:
+		return // Says nothing about the product
+	case stock.OutcomeSkipped:
+		summary.Skipped++
+	case stock.OutcomeCaptcha:
+		summary.Captchas++
+	case stock.OutcomeSuccess:
+		if result.InStock {
+			summary.InStock++
+		}
+		if alerted {
+			summary.Alerts++
+		}
+	default:
+		summary.Failures++
+	}
+	summary.Checks++
+
+	entry := history.Entry{
+		Time:      result.CheckedAt,
+		ProductID: product.ID,
+		Product:   product.DisplayName(),
+		Outcome:   result.Outcome.String(),
+		InStock:   result.InStock,
+		Indicator: result.Indicator,
+		Location:  result.Location,
+		Price:     result.Price,
+		Currency:  result.Currency,
+		Seller:    result.Seller,
+		Condition: result.Condition,
+		Alerted:   alerted,
+		Rule:      rule,
+	}
+	if result.Err != nil {
+		entry.Error = result.Err.Error()
+	}
+	if err := store.Record(entry); err != nil {
+		ui.LogError("%v", err)
+		return
+	}
+	if err := store.Flush(); err != nil {
+		ui.LogError("%v", err)
 	}
 }
@@ -0,0 +1,175 @@
+package rules
+
+// node is a type-checked expression node
+type node interface {
+	typ() Type
+	eval(vars Vars) any
+}
+
+type literal struct {
+	t     Type
+	value any
+}
+
+func (n literal) typ() Type     { return n.t }
+func (n literal) eval(Vars) any { return n.value }
+
+// constant returns the value of a number or string literal, including
+// negated numbers
+func constant(n node) (any, bool) {
+	switch n := n.(type) {
+	case literal:
+		return n.value, n.t == Number || n.t == String
+	case negNode:
+		if value, ok := constant(n.operand); ok {
+			return -value.(float64), true
+		}
+	}
+	return nil, false
+}
+
+type variable struct {
+	name string
+	t    Type
+}
+
+func (n variable) typ() Type { return n.t }
+
+func (n variable) eval(vars Vars) any {
+	value, ok := vars[n.name]
+	switch n.t {
+	case Bool:
+		b, _ := value.(bool)
+		return b
+	case Number:
+		switch v := value.(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		}
+		return 0.0
+	case String:
+		s, _ := value.(string)
+		return s
+	}
+	if !ok {
+		return []any(nil)
+	}
+	return value
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) typ() Type { return Bool }
+func (n orNode) eval(vars Vars) any {
+	return n.left.eval(vars).(bool) || n.right.eval(vars).(bool)
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) typ() Type { return Bool }
+func (n andNode) eval(vars Vars) any {
+	return n.left.eval(vars).(bool) && n.right.eval(vars).(bool)
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) typ() Type          { return Bool }
+func (n notNode) eval(vars Vars) any { return !n.operand.eval(vars).(bool) }
+
+type negNode struct{ operand node }
+
+func (n negNode) typ() Type          { return Number }
+func (n negNode) eval(vars Vars) any { return -n.operand.eval(vars).(float64) }
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) typ() Type { return Bool }
+
+func (n compareNode) eval(vars Vars) any {
+	left, right := n.left.eval(vars), n.right.eval(vars)
+	switch n.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r := right.(float64)
+		cmp = compare(l < r, l > r)
+	case string:
+		r := right.(string)
+		cmp = compare(l < r, l > r)
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+type inNode struct{ item, list node }
+
+func (n inNode) typ() Type { return Bool }
+
+func (n inNode) eval(vars Vars) any {
+	item := n.item.eval(vars)
+	switch list := n.list.eval(vars).(type) {
+	case []any:
+		for _, element := range list {
+			if element == item {
+				return true
+			}
+		}
+	case []string:
+		for _, element := range list {
+			if element == item {
+				return true
+			}
+		}
+	case []float64:
+		for _, element := range list {
+			if element == item {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type callNode struct {
+	fn   func(args []any) any
+	args []node
+	t    Type
+}
+
+func (n callNode) typ() Type { return n.t }
+
+func (n callNode) eval(vars Vars) any {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(vars)
+	}
+	return n.fn(args)
+}
@@ -0,0 +1,488 @@
+// Package rules implements the expression language of alert rules, e.g.
+//
+//	state == "in_stock" && price <= 1999 && seller in ["Amazon.com"]
+//
+// Expressions are compiled once against a schema of typed variables, so
+// unknown names and type mismatches are reported before any evaluation.
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a value in an expression
+type Type int
+
+// Expression types
+const (
+	Bool Type = iota
+	Number
+	String
+	NumberList
+	StringList
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case NumberList:
+		return "list of numbers"
+	case StringList:
+		return "list of strings"
+	}
+	return "unknown"
+}
+
+// elem returns the element type of a list type
+func (t Type) elem() (Type, bool) {
+	switch t {
+	case NumberList:
+		return Number, true
+	case StringList:
+		return String, true
+	}
+	return 0, false
+}
+
+// Schema declares the variables an expression may use and their types
+type Schema map[string]Type
+
+// Vars are the values of the variables, by name: bool, float64 or string
+// as declared in the schema. Missing variables evaluate to the zero value.
+type Vars map[string]any
+
+// Expr is a compiled, type-checked boolean expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses src and checks it against schema. The expression must be
+// of type bool.
+func Compile(src string, schema Schema) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, schema: schema}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	if root.typ() != Bool {
+		return nil, fmt.Errorf("expression is a %s, not a condition", root.typ())
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval reports whether the expression holds for vars
+func (e *Expr) Eval(vars Vars) bool {
+	return e.root.eval(vars).(bool)
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string // Operator or identifier; decoded value of strings
+	num  float64
+	pos  int // Byte offset in the source
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are matched longest first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "-"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("column %d: unterminated string", i+1)
+			}
+			literal := src[i : end+1]
+			if c == '\'' {
+				literal = doubleQuoted(src[i+1 : end])
+			}
+			text, err := strconv.Unquote(literal)
+			if err != nil {
+				return nil, fmt.Errorf("column %d: invalid string %s", i+1, src[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '.':
+			end := i
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.' || src[end] == '_') {
+				end++
+			}
+			num, err := strconv.ParseFloat(strings.ReplaceAll(src[i:end], "_", ""), 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %d: invalid number %s", i+1, src[i:end])
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[i:end], num: num, pos: i})
+			i = end
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := i
+			for end < len(src) && (src[end] == '_' || src[end] >= 'a' && src[end] <= 'z' || src[end] >= 'A' && src[end] <= 'Z' || src[end] >= '0' && src[end] <= '9') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("column %d: unexpected character %q", i+1, c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// doubleQuoted turns the body of a single-quoted string into a Go string
+// literal with the same escapes: \' becomes ' and a bare " is escaped
+func doubleQuoted(body string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\' && i+1 < len(body):
+			if body[i+1] != '\'' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(body[i+1])
+			i++
+		case body[i] == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(body[i])
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parser is a recursive-descent parser that type-checks as it builds nodes.
+// Precedence from loosest: ||, &&, comparisons and in, unary ! and -.
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOp(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.next(); tok.kind != tokOp || tok.text != text {
+		return p.errorf(tok, "expected %q, found %s", text, tok)
+	}
+	return nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		tok := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBool(tok, left, right); err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		tok := p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBool(tok, left, right); err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) wantBool(tok token, operands ...node) error {
+	for _, operand := range operands {
+		if operand.typ() != Bool {
+			return p.errorf(tok, "%s needs conditions on both sides, found a %s", tok.text, operand.typ())
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	switch {
+	case tok.kind == tokIdent && tok.text == "in":
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		elem, ok := right.typ().elem()
+		if !ok || elem != left.typ() {
+			return nil, p.errorf(tok, "cannot look for a %s in a %s", left.typ(), right.typ())
+		}
+		return inNode{left, right}, nil
+	case tok.kind == tokOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != right.typ() {
+			return nil, p.errorf(tok, "cannot compare a %s with a %s", left.typ(), right.typ())
+		}
+		ordered := tok.text != "==" && tok.text != "!="
+		if ordered && left.typ() != Number && left.typ() != String {
+			return nil, p.errorf(tok, "%s does not apply to a %s", tok.text, left.typ())
+		}
+		if !ordered && left.typ() != Bool && left.typ() != Number && left.typ() != String {
+			return nil, p.errorf(tok, "%s does not apply to a %s", tok.text, left.typ())
+		}
+		return compareNode{op: tok.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != Bool {
+			return nil, p.errorf(tok, "! needs a condition, found a %s", operand.typ())
+		}
+		return notNode{operand}, nil
+	}
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != Number {
+			return nil, p.errorf(tok, "- needs a number, found a %s", operand.typ())
+		}
+		return negNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return literal{Number, tok.num}, nil
+	case tokString:
+		return literal{String, tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return literal{Bool, tok.text == "true"}, nil
+		case "in":
+			return nil, p.errorf(tok, "unexpected in")
+		}
+		if p.isOp("(") {
+			return p.parseCall(tok)
+		}
+		typ, ok := p.schema[tok.text]
+		if !ok {
+			return nil, p.errorf(tok, "unknown name %s (known: %s)", tok.text, strings.Join(p.schema.names(), ", "))
+		}
+		return variable{name: tok.text, t: typ}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			return p.parseList(tok)
+		}
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// parseList parses a list literal; all elements must be literals of one type
+func (p *parser) parseList(open token) (node, error) {
+	var items []any
+	var elem Type = -1
+	for !p.isOp("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		tok := p.peek()
+		item, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		value, ok := constant(item)
+		if !ok {
+			return nil, p.errorf(tok, "list elements must be literal numbers or strings")
+		}
+		if elem >= 0 && item.typ() != elem {
+			return nil, p.errorf(tok, "list mixes %s and %s elements", elem, item.typ())
+		}
+		elem = item.typ()
+		items = append(items, value)
+	}
+	p.next()
+
+	switch elem {
+	case Number:
+		return literal{NumberList, items}, nil
+	case String, -1:
+		return literal{StringList, items}, nil
+	}
+	return nil, p.errorf(open, "lists may hold numbers or strings, not %s", elem)
+}
+
+// functions are the built-in functions: argument types and result type
+var functions = map[string]struct {
+	args   []Type
+	result Type
+	call   func(args []any) any
+}{
+	"contains": {[]Type{String, String}, Bool, func(args []any) any {
+		return strings.Contains(args[0].(string), args[1].(string))
+	}},
+	"lower": {[]Type{String}, String, func(args []any) any {
+		return strings.ToLower(args[0].(string))
+	}},
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	p.next() // (
+	var args []node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != len(fn.args) {
+		return nil, p.errorf(name, "%s takes %d argument(s), got %d", name.text, len(fn.args), len(args))
+	}
+	for i, arg := range args {
+		if arg.typ() != fn.args[i] {
+			return nil, p.errorf(name, "argument %d of %s must be a %s, found a %s", i+1, name.text, fn.args[i], arg.typ())
+		}
+	}
+	return callNode{fn: fn.call, args: args, t: fn.result}, nil
+}
+
+func (s Schema) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
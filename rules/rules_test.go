@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSchema has the variables alert rules use
+var testSchema = Schema{
+	"state":     String,
+	"in_stock":  Bool,
+	"price":     Number,
+	"currency":  String,
+	"seller":    String,
+	"condition": String,
+	"title":     String,
+}
+
+var inStockAtAmazon = Vars{
+	"state": "in_stock", "in_stock": true, "price": 1999.0, "currency": "USD",
+	"seller": "Amazon.com", "condition": "new", "title": "GeForce RTX 5090 Founders Edition",
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		vars Vars
+		want bool
+	}{
+		// The examples of the README
+		{`state == "in_stock" && price <= 1999 && seller in ["Amazon.com", "NVIDIA"] && condition == "new"`, inStockAtAmazon, true},
+		{`state == "in_stock" && price <= 1999 && seller in ["Amazon.com", "NVIDIA"] && condition == "new"`, Vars{"state": "in_stock", "price": 2100.0, "seller": "Amazon.com", "condition": "new"}, false},
+		{`in_stock && condition == "new" && contains(lower(seller), "amazon")`, inStockAtAmazon, true},
+		{`in_stock && condition == "new" && contains(lower(seller), "amazon")`, Vars{"in_stock": true, "condition": "new", "seller": "Vendor"}, false},
+
+		// Missing variables are zero
+		{`price == 0 && seller == "" && !in_stock`, Vars{}, true},
+
+		// && binds tighter than ||, comparisons tighter than both
+		{`true || false && false`, nil, true},
+		{`(true || false) && false`, nil, false},
+		{`false && false || true`, nil, true},
+		{`!in_stock || price < 10`, inStockAtAmazon, false},
+		{`!(in_stock && price < 10)`, inStockAtAmazon, true},
+		{`!!in_stock`, inStockAtAmazon, true},
+		{`-price < -1998.5`, inStockAtAmazon, true},
+		{`price >= 1_999 && price <= 1999.0`, inStockAtAmazon, true},
+
+		// Strings compare by bytes; lists hold literals
+		{`seller < "B" && "b" > seller`, inStockAtAmazon, true},
+		{`price in [999, 1999]`, inStockAtAmazon, true},
+		{`seller in []`, inStockAtAmazon, false},
+		{`condition != "used" && in_stock == true`, inStockAtAmazon, true},
+
+		// Quoting
+		{`seller == 'a\'b'`, Vars{"seller": "a'b"}, true},
+		{`seller == "a'b"`, Vars{"seller": "a'b"}, true},
+		{`seller == 'say "hi"'`, Vars{"seller": `say "hi"`}, true},
+		{`seller == "say \"hi\""`, Vars{"seller": `say "hi"`}, true},
+		{`seller == 'back\\slash'`, Vars{"seller": `back\slash`}, true},
+		{`seller == 'tab\there'`, Vars{"seller": "tab\there"}, true},
+		{`seller in ['it\'s', "it's"]`, Vars{"seller": "it's"}, true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, testSchema)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tt.src, err)
+			continue
+		}
+		if got := expr.Eval(tt.vars); got != tt.want {
+			t.Errorf("%s = %t with %v, want %t", tt.src, got, tt.vars, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		errText string
+	}{
+		{`price == "x"`, "column 7: cannot compare a number with a string"},
+		{`seller in [1]`, "column 8: cannot look for a string in a list of numbers"},
+		{`price in ["1999"]`, "cannot look for a number in a list of strings"},
+		{`seller in "Amazon"`, "cannot look for a string in a string"},
+		{`[1, "a"] == [1]`, "list mixes number and string elements"},
+		{`seller in [condition]`, "list elements must be literal numbers or strings"},
+		{`in_stock < true`, "< does not apply to a bool"},
+		{`price && in_stock`, "&& needs conditions on both sides, found a number"},
+		{`in_stock || seller`, "|| needs conditions on both sides, found a string"},
+		{`!price`, "! needs a condition, found a number"},
+		{`-seller == 1`, "- needs a number, found a string"},
+		{`lower(price) == ""`, "argument 1 of lower must be a string, found a number"},
+		{`contains(seller)`, "contains takes 2 argument(s), got 1"},
+		{`upper(seller) == ""`, "unknown function upper"},
+		{`stock > 0`, "unknown name stock (known: condition, currency, in_stock, price, seller, state, title)"},
+		{`price`, "expression is a number, not a condition"},
+		{`price > 1 > 0`, "unexpected \">\""},
+		{`(in_stock`, `expected ")"`},
+		{`in_stock &&`, "unexpected end of expression"},
+		{`seller == 'abc`, "column 11: unterminated string"},
+		{`seller == 'abc\'`, "unterminated string"},
+		{`seller == "\q"`, "invalid string"},
+		{`price == 1.2.3`, "invalid number"},
+		{`price = 1`, "unexpected character '='"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src, testSchema)
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("Compile(%s) = %v, want an error containing %q", tt.src, err, tt.errText)
+		}
+	}
+}
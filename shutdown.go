@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gpu-sniper/alerts"
+	"gpu-sniper/config"
+	"gpu-sniper/history"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+)
+
+// Process exit codes
+const (
+	exitOK         = 0 // Clean shutdown
+	exitStartup    = 1 // Configuration or startup failure
+	exitForced     = 2 // Second signal skipped the graceful shutdown
+	exitIncomplete = 3 // A shutdown step failed; cookies, history or alerts may be lost
+)
+
+// shutdown runs once the scheduler has stopped and no check is in flight.
+// Every step runs even if an earlier one failed; the returned exit code
+// reports whether all of them completed.
+func shutdown(session *httpClient.Session, store *history.Store, summary ui.SessionSummary) int {
+	ui.LogInfo("Saving session cookies")
+	if err := session.Close(); err != nil {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("cookies: %v", err))
+	}
+
+	if store != nil {
+		ui.LogInfo("Closing history file %s", config.HistoryFile)
+		if err := store.Close(); err != nil {
+			summary.Problems = append(summary.Problems, fmt.Sprintf("history: %v", err))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := alerts.Drain(ctx); err != nil {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("alerts: %v", err))
+	}
+
+	ui.PrintSessionSummary(summary)
+	if len(summary.Problems) > 0 {
+		ui.LogWarning("Shutdown incomplete")
+		return exitIncomplete
+	}
+	ui.LogInfo("Shutdown complete")
+	return exitOK
+}
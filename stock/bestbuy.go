@@ -0,0 +1,158 @@
+package stock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gpu-sniper/config"
+	"gpu-sniper/ui"
+)
+
+// bestBuyFields are the product attributes requested from the Products API
+const bestBuyFields = "sku,name,onlineAvailability,orderable,salePrice,regularPrice,addToCartUrl,url"
+
+// BestBuyProduct is the part of a Products API response the check uses
+type BestBuyProduct struct {
+	SKU                int     `json:"sku"`
+	Name               string  `json:"name"`
+	OnlineAvailability bool    `json:"onlineAvailability"`
+	Orderable          string  `json:"orderable"` // "Available", "SoldOut", "ComingSoon", "PreOrder", ...
+	SalePrice          float64 `json:"salePrice"`
+	RegularPrice       float64 `json:"regularPrice"`
+	AddToCartURL       string  `json:"addToCartUrl"`
+	URL                string  `json:"url"`
+}
+
+// InStock reports whether the product can be ordered online right now
+func (p BestBuyProduct) InStock() bool {
+	return p.OnlineAvailability && (p.Orderable == "Available" || p.Orderable == "PreOrder")
+}
+
+// BestBuyPipeline turns a Products API response into a result
+var BestBuyPipeline = []Stage{
+	{Name: "api-error", Run: CheckBestBuyError},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParseBestBuyAvailability},
+}
+
+// bestBuySource checks Best Buy SKUs through the Products API
+type bestBuySource struct{}
+
+func (bestBuySource) Name() string { return "bestbuy" }
+
+func (bestBuySource) Prepare(context.Context, config.Product) string { return "" }
+
+func (bestBuySource) Fetch(ctx context.Context, check *Check) error {
+	endpoint := fmt.Sprintf("%s/v1/products/%s.json?%s", config.BestBuyBaseURL, url.PathEscape(check.Product.ID),
+		url.Values{"show": {bestBuyFields}, "apiKey": {config.BestBuyAPIKey}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// The URL carries the API key, so only the SKU is logged and the key is
+	// removed from request errors and the page handed to scripts
+	ui.LogInfo("Querying Best Buy Products API for SKU %s", check.Product.ID)
+	page, err := Session.FetchPage(req)
+	if err != nil {
+		return redactBestBuyError(err)
+	}
+	page.URL = redactBestBuyURL(page.URL)
+	check.Page = page
+	return RunPipeline(check, BestBuyPipeline)
+}
+
+// redactBestBuyError rebuilds a request error with the API key removed from
+// its URL. Other errors are returned unchanged.
+func redactBestBuyError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = redactBestBuyURL(urlErr.URL)
+	return fmt.Errorf("network error: failed to query the Best Buy Products API: %w", &redacted)
+}
+
+// redactBestBuyURL replaces the apiKey parameter of a Products API URL
+func redactBestBuyURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(unparsable Best Buy API URL)"
+	}
+	query := u.Query()
+	if !query.Has("apiKey") {
+		return rawURL
+	}
+	query.Set("apiKey", "REDACTED")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// CheckBestBuyError turns Products API client errors into readable errors.
+// Over-quota responses are left to CheckResponseStatus as rate limiting.
+func CheckBestBuyError(check *Check) error {
+	status := check.Page.StatusCode
+	if status < 400 || status >= 500 || status == http.StatusForbidden || status == http.StatusTooManyRequests {
+		return nil
+	}
+
+	var reply struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := ""
+	if json.Unmarshal(check.Page.Body(), &reply) == nil {
+		message = reply.Error.Message
+	}
+	switch status {
+	case http.StatusNotFound:
+		return Permanent(fmt.Errorf("Best Buy SKU %s not found", check.Product.ID))
+	case http.StatusUnauthorized, http.StatusBadRequest:
+		if strings.Contains(strings.ToLower(message), "key") {
+			return Permanent(fmt.Errorf("Best Buy rejected the API key: %s", message))
+		}
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return Permanent(fmt.Errorf("Best Buy API error %d: %s", status, message))
+}
+
+// ParseBestBuyAvailability decides availability from a Products API response
+func ParseBestBuyAvailability(check *Check) error {
+	var product BestBuyProduct
+	if err := json.Unmarshal(check.Page.Body(), &product); err != nil {
+		return fmt.Errorf("failed to parse Best Buy response: %w", err)
+	}
+	if product.SKU == 0 {
+		return fmt.Errorf("Best Buy response has no product for SKU %s", check.Product.ID)
+	}
+
+	price := product.SalePrice
+	if price == 0 {
+		price = product.RegularPrice
+	}
+	ui.LogInfo("Best Buy: online availability %t, orderable %q", product.OnlineAvailability, product.Orderable)
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   product.InStock(),
+		Indicator: "orderable=" + product.Orderable,
+		CheckedAt: check.Page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Price:     price,
+		Currency:  "USD",
+		CartURL:   product.AddToCartURL,
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
@@ -0,0 +1,131 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+const testBestBuyKey = "secret-test-key"
+
+// bestBuyServer mocks the Products API: each SKU maps to a status and body
+func bestBuyServer(t *testing.T, replies map[string]struct {
+	status int
+	body   string
+}) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apiKey") != testBestBuyKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":401,"message":"The provided API Key is invalid."}}`))
+			return
+		}
+		sku := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/products/"), ".json")
+		reply, ok := replies[sku]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"Resource not found"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(reply.status)
+		w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(server.Close)
+
+	session, err := httpClient.Open(httpClient.SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSession, oldURL, oldKey := Session, config.BestBuyBaseURL, config.BestBuyAPIKey
+	Session, config.BestBuyBaseURL, config.BestBuyAPIKey = session, server.URL, testBestBuyKey
+	t.Cleanup(func() {
+		Session, config.BestBuyBaseURL, config.BestBuyAPIKey = oldSession, oldURL, oldKey
+		session.Close()
+	})
+}
+
+func TestBestBuySource(t *testing.T) {
+	bestBuyServer(t, map[string]struct {
+		status int
+		body   string
+	}{
+		"6614151": {200, `{"sku":6614151,"name":"GeForce RTX 5090","onlineAvailability":true,"orderable":"Available",
+			"salePrice":1999.99,"regularPrice":1999.99,"addToCartUrl":"https://api.bestbuy.com/click/-/6614151/cart"}`},
+		"6614153": {200, `{"sku":6614153,"name":"GeForce RTX 5080","onlineAvailability":false,"orderable":"SoldOut","regularPrice":999.99}`},
+		"6614155": {403, `{"error":{"code":403,"message":"Over quota"}}`},
+	})
+
+	tests := []struct {
+		name      string
+		sku       string
+		key       string
+		inStock   bool
+		price     float64
+		cartURL   string
+		permanent bool // The product should not be checked again
+		outcome   Outcome
+		errText   string // Part of the error, empty for none
+	}{
+		{"available", "6614151", testBestBuyKey, true, 1999.99, "https://api.bestbuy.com/click/-/6614151/cart", false, OutcomeSuccess, ""},
+		{"sold out", "6614153", testBestBuyKey, false, 999.99, "", false, OutcomeSuccess, ""},
+		{"not found", "1234567", testBestBuyKey, false, 0, "", true, OutcomeError, "SKU 1234567 not found"},
+		{"bad key", "6614151", "wrong-key", false, 0, "", true, OutcomeError, "rejected the API key: The provided API Key is invalid."},
+		{"over quota", "6614155", testBestBuyKey, false, 0, "", false, OutcomeRateLimited, "403"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.BestBuyAPIKey = tt.key
+			defer func() { config.BestBuyAPIKey = testBestBuyKey }()
+
+			product := config.Product{ID: tt.sku, Retailer: config.RetailerBestBuy}
+			check := &Check{Product: product, Outcome: CheckOutcome{Outcome: OutcomeError}}
+			err := bestBuySource{}.Fetch(context.Background(), check)
+			if tt.errText == "" && err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+				t.Fatalf("Fetch = %v, want an error containing %q", err, tt.errText)
+			}
+			if got := errors.Is(err, ErrPermanent); got != tt.permanent {
+				t.Errorf("permanent = %t, want %t (%v)", got, tt.permanent, err)
+			}
+			if check.Outcome.Outcome != tt.outcome {
+				t.Errorf("outcome = %v, want %v", check.Outcome.Outcome, tt.outcome)
+			}
+			if err != nil {
+				return
+			}
+			got := check.Result
+			if got.InStock != tt.inStock || got.Price != tt.price || got.CartURL != tt.cartURL || got.Currency != "USD" {
+				t.Errorf("result = %+v, want in stock %t at %v with cart %q", got, tt.inStock, tt.price, tt.cartURL)
+			}
+			if strings.Contains(check.Page.URL, testBestBuyKey) {
+				t.Errorf("page URL %q carries the API key", check.Page.URL)
+			}
+		})
+	}
+}
+
+func TestBestBuyErrorsHideTheKey(t *testing.T) {
+	bestBuyServer(t, nil)
+	config.BestBuyBaseURL = "http://127.0.0.1:0" // Nothing listens there
+
+	check := &Check{Product: config.Product{ID: "6614151", Retailer: config.RetailerBestBuy}}
+	err := bestBuySource{}.Fetch(context.Background(), check)
+	if err == nil {
+		t.Fatal("Fetch succeeded without a server")
+	}
+	if strings.Contains(err.Error(), testBestBuyKey) {
+		t.Fatalf("error %q carries the API key", err)
+	}
+	if !strings.Contains(err.Error(), "apiKey=REDACTED") {
+		t.Errorf("error %q does not show the redacted URL", err)
+	}
+}
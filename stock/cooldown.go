@@ -0,0 +1,101 @@
+package stock
+
+import (
+	"sync"
+	"time"
+
+	"gpu-sniper/config"
+)
+
+// cooldownState tracks the cooling-down period a host entered after serving
+// a block page. While cooling down no requests are sent to the host.
+type cooldownState struct {
+	mu      sync.Mutex
+	until   time.Time
+	strikes int // Consecutive checks that hit a CAPTCHA
+}
+
+var (
+	cooldownsMu sync.Mutex
+	cooldowns   = make(map[string]*cooldownState) // By host
+)
+
+// cooldownFor returns the cooling-down state of a host
+func cooldownFor(host string) *cooldownState {
+	cooldownsMu.Lock()
+	defer cooldownsMu.Unlock()
+	c, ok := cooldowns[host]
+	if !ok {
+		c = &cooldownState{}
+		cooldowns[host] = c
+	}
+	return c
+}
+
+// Cooldown is the cooling-down state of one host
+type Cooldown struct {
+	Until   time.Time // When checks resume
+	Strikes int       // Consecutive checks that hit a CAPTCHA
+}
+
+// activeCooldowns returns the hosts that are cooling down at now
+func activeCooldowns(now time.Time) map[string]Cooldown {
+	cooldownsMu.Lock()
+	defer cooldownsMu.Unlock()
+	active := make(map[string]Cooldown)
+	for host, c := range cooldowns {
+		if until, strikes := c.snapshot(); now.Before(until) {
+			active[host] = Cooldown{Until: until, Strikes: strikes}
+		}
+	}
+	return active
+}
+
+// enter starts (or escalates) a cooling-down period and returns its length.
+// Each consecutive CAPTCHA multiplies the period by CaptchaRetryConfig.BackoffFactor.
+func (c *cooldownState) enter(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.strikes++
+	period := config.CaptchaRetryConfig.InitialBackoff
+	for i := 1; i < c.strikes; i++ {
+		period = time.Duration(float64(period) * config.CaptchaRetryConfig.BackoffFactor)
+		if period >= config.CaptchaRetryConfig.MaxBackoff {
+			period = config.CaptchaRetryConfig.MaxBackoff
+			break
+		}
+	}
+
+	c.until = now.Add(period)
+	return period
+}
+
+// remaining returns how long the cooling-down period still lasts
+func (c *cooldownState) remaining(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.until) {
+		return c.until.Sub(now)
+	}
+	return 0
+}
+
+// reset clears the strike count after a clean check
+func (c *cooldownState) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strikes = 0
+	c.until = time.Time{}
+}
+
+func (c *cooldownState) snapshot() (time.Time, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.until, c.strikes
+}
+
+// cooldownStatusText is shown in the progress tracker while cooling down
+func cooldownStatusText(host string, until time.Time) string {
+	return "Cooling Down - CAPTCHA on " + host + " (resumes " + until.Format("03:04 PM") + ")"
+}
@@ -0,0 +1,286 @@
+package stock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+)
+
+// CustomPipeline turns a response to a config-defined source's request into
+// a result
+var CustomPipeline = []Stage{
+	{Name: "debug", Run: SaveDebugPage},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParseCustomAvailability},
+}
+
+// customSource checks products with a source defined in the configuration
+// file, compiled by CompileCustomSources
+type customSource struct {
+	name         string
+	def          config.CustomSource
+	availability *extractor
+	price        *extractor
+	title        *extractor
+}
+
+// customSources are the compiled config-defined sources, by name
+var customSources = map[string]*customSource{}
+
+// CompileCustomSources compiles the rules of config.CustomSources so rule
+// errors are reported at startup rather than on the first check
+func CompileCustomSources() error {
+	compiled := make(map[string]*customSource, len(config.CustomSources))
+	for name, def := range config.CustomSources {
+		source := &customSource{name: name, def: def}
+		var err error
+		if source.availability, err = compileRule(def.Availability); err != nil {
+			return fmt.Errorf("source %s: availability: %w", name, err)
+		}
+		if def.Price != nil {
+			if source.price, err = compileRule(*def.Price); err != nil {
+				return fmt.Errorf("source %s: price: %w", name, err)
+			}
+		}
+		if def.Title != nil {
+			if source.title, err = compileRule(*def.Title); err != nil {
+				return fmt.Errorf("source %s: title: %w", name, err)
+			}
+		}
+		compiled[name] = source
+	}
+	customSources = compiled
+	return nil
+}
+
+func (s *customSource) Name() string { return s.name }
+
+func (s *customSource) Prepare(context.Context, config.Product) string { return "" }
+
+func (s *customSource) Fetch(ctx context.Context, check *Check) error {
+	endpoint := s.def.RequestURL(check.Product.ID)
+	var body io.Reader
+	if s.def.Body != "" {
+		body = strings.NewReader(s.def.RequestBody(check.Product.ID))
+	}
+	req, err := http.NewRequestWithContext(ctx, s.def.Method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", Session.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	for name, value := range s.def.Headers {
+		req.Header.Set(name, value)
+	}
+
+	ui.LogInfo("Fetching %s: %s %s", s.name, s.def.Method, endpoint)
+	page, err := Session.FetchPage(req)
+	if err != nil {
+		return err
+	}
+	check.Page = page
+	return RunPipeline(check, CustomPipeline)
+}
+
+// Extraction is what a config-defined source's rules found in a response
+type Extraction struct {
+	Availability string  // Availability value after post-processing
+	Found        bool    // Whether the availability rule matched at all
+	InStock      bool    // Availability decided from the value
+	PriceText    string  // Price value after post-processing
+	Price        float64 // PriceText parsed, 0 if absent or unreadable
+	Title        string
+}
+
+// ExtractCustom runs the rules of the named source against a response
+func ExtractCustom(name string, page *httpClient.Page) (Extraction, error) {
+	source, ok := customSources[name]
+	if !ok {
+		return Extraction{}, fmt.Errorf("unknown source %q", name)
+	}
+	return source.extract(page)
+}
+
+func (s *customSource) extract(page *httpClient.Page) (Extraction, error) {
+	var result Extraction
+	var err error
+	result.Availability, result.Found, err = s.availability.extract(page)
+	if err != nil {
+		return result, fmt.Errorf("availability: %w", err)
+	}
+	result.InStock = s.availability.inStock(result.Availability, result.Found)
+
+	if s.price != nil {
+		var found bool
+		if result.PriceText, found, err = s.price.extract(page); err != nil {
+			return result, fmt.Errorf("price: %w", err)
+		}
+		if found {
+			result.Price, _ = ParsePrice(result.PriceText, s.def.PriceFormat())
+		}
+	}
+	if s.title != nil {
+		if result.Title, _, err = s.title.extract(page); err != nil {
+			return result, fmt.Errorf("title: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// ParseCustomAvailability decides availability with the rules of the
+// product's config-defined source
+func ParseCustomAvailability(check *Check) error {
+	source, ok := customSources[check.Product.Source]
+	if !ok {
+		return Permanent(fmt.Errorf("unknown source %q", check.Product.Source))
+	}
+	extraction, err := source.extract(check.Page)
+	if err != nil {
+		return fmt.Errorf("failed to apply %s rules: %w", source.name, err)
+	}
+	if extraction.Title != "" {
+		ui.LogInfo("Title: %s", extraction.Title)
+	}
+	ui.LogInfo("%s: availability %q", source.name, extraction.Availability)
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   extraction.InStock,
+		Indicator: "availability=" + extraction.Availability,
+		CheckedAt: check.Page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Price:     extraction.Price,
+		Currency:  source.def.Currency,
+		CartURL:   check.Product.URL,
+		Title:     extraction.Title,
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// extractor is a compiled config.ExtractRule
+type extractor struct {
+	kind      string // "css", "xpath" or "jsonpath"
+	css       cascadia.Selector
+	attr      string
+	xpath     xpathExpr
+	jsonPath  jsonPath
+	regex     *regexp.Regexp
+	inStockRE *regexp.Regexp
+}
+
+func compileRule(rule config.ExtractRule) (*extractor, error) {
+	e := &extractor{attr: rule.Attr}
+	var err error
+	switch {
+	case rule.CSS != "":
+		e.kind = "css"
+		if e.css, err = cascadia.Compile(rule.CSS); err != nil {
+			return nil, fmt.Errorf("css %q: %w", rule.CSS, err)
+		}
+	case rule.XPath != "":
+		e.kind = "xpath"
+		if e.xpath, err = compileXPath(rule.XPath); err != nil {
+			return nil, err
+		}
+	case rule.JSONPath != "":
+		e.kind = "jsonpath"
+		if e.jsonPath, err = compileJSONPath(rule.JSONPath); err != nil {
+			return nil, err
+		}
+	}
+	if rule.Regex != "" {
+		e.regex = regexp.MustCompile(rule.Regex) // Checked by config.Load
+	}
+	if rule.InStock != "" {
+		e.inStockRE = regexp.MustCompile(rule.InStock)
+	}
+	return e, nil
+}
+
+// extract returns the first value the rule selects, post-processed by its
+// regex, and whether anything was selected
+func (e *extractor) extract(page *httpClient.Page) (string, bool, error) {
+	var values []string
+	switch e.kind {
+	case "jsonpath":
+		var doc any
+		if err := json.Unmarshal(page.Body(), &doc); err != nil {
+			return "", false, fmt.Errorf("response is not JSON: %w", err)
+		}
+		for _, value := range e.jsonPath.eval(doc) {
+			if text, ok := jsonText(value); ok {
+				values = append(values, text)
+			}
+		}
+	case "xpath":
+		doc, err := page.Document()
+		if err != nil {
+			return "", false, err
+		}
+		if len(doc.Nodes) > 0 {
+			values = e.xpath.eval(doc.Nodes[0])
+		}
+	default:
+		doc, err := page.Document()
+		if err != nil {
+			return "", false, err
+		}
+		doc.FindMatcher(e.css).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+			if e.attr == "" {
+				values = append(values, selection.Text())
+				return false
+			}
+			if value, ok := selection.Attr(e.attr); ok {
+				values = append(values, value)
+				return false
+			}
+			return true
+		})
+	}
+	if len(values) == 0 {
+		return "", false, nil
+	}
+
+	value := strings.Join(strings.Fields(values[0]), " ")
+	if e.regex == nil {
+		return value, true, nil
+	}
+	match := e.regex.FindStringSubmatch(value)
+	switch {
+	case match == nil:
+		return "", false, nil
+	case len(match) > 1:
+		return match[1], true, nil
+	default:
+		return match[0], true, nil
+	}
+}
+
+// inStock decides availability from an extracted value. With an in_stock
+// pattern the value must match it; otherwise any match counts unless its
+// value says false, 0 or no.
+func (e *extractor) inStock(value string, found bool) bool {
+	if !found {
+		return false
+	}
+	if e.inStockRE != nil {
+		return e.inStockRE.MatchString(value)
+	}
+	switch strings.ToLower(value) {
+	case "false", "0", "no":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package stock
+
+import (
+	"net/http"
+	"testing"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+func TestExtractCustomPriceDecimal(t *testing.T) {
+	saved := config.CustomSources
+	defer func() {
+		config.CustomSources = saved
+		CompileCustomSources()
+	}()
+
+	tests := []struct {
+		decimal string
+		text    string
+		want    float64
+	}{
+		{"", "1,299.00", 1299},
+		{".", "1,299", 1299},
+		{".", "12.999", 12.999},
+		{",", "1.299,00", 1299},
+		{",", "1.299", 1299},
+		{",", "12,50", 12.5},
+	}
+	for _, tt := range tests {
+		config.CustomSources = map[string]config.CustomSource{"shop": {
+			URL:          "https://shop.example/products/{id}",
+			Decimal:      tt.decimal,
+			Availability: config.ExtractRule{CSS: "#stock"},
+			Price:        &config.ExtractRule{CSS: ".price"},
+		}}
+		if err := CompileCustomSources(); err != nil {
+			t.Fatalf("CompileCustomSources: %v", err)
+		}
+		body := `<div id="stock">In stock</div><span class="price">` + tt.text + ` €</span>`
+		page := httpClient.NewPage("https://shop.example/products/1", http.StatusOK, http.Header{}, []byte(body))
+		extraction, err := ExtractCustom("shop", page)
+		if err != nil {
+			t.Fatalf("ExtractCustom: %v", err)
+		}
+		if extraction.Price != tt.want {
+			t.Errorf("price %q with decimal %q = %v, want %v", tt.text, tt.decimal, extraction.Price, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package stock
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is one step of a compiled JSONPath expression
+type jsonStep struct {
+	key       string // Object member to select; empty for index and wildcard steps
+	index     int    // Array element to select when isIndex is set; negative counts from the end
+	isIndex   bool
+	wildcard  bool // Every member or element
+	recursive bool // Search key at any depth (..key)
+}
+
+// jsonPath is a compiled JSONPath expression. The supported subset covers
+// what shop APIs need: $.a.b, $['a'], $.a[0], $.a[-1], $.a[*].b and $..a.
+type jsonPath []jsonStep
+
+// compileJSONPath parses a JSONPath expression
+func compileJSONPath(expr string) (jsonPath, error) {
+	rest := strings.TrimSpace(expr)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("jsonpath %q must start with $", expr)
+	}
+	rest = rest[1:]
+
+	var path jsonPath
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			key, remainder := jsonPathKey(rest[2:])
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath %q: missing name after ..", expr)
+			}
+			path = append(path, jsonStep{key: key, recursive: true, wildcard: key == "*"})
+			rest = remainder
+		case strings.HasPrefix(rest, "."):
+			key, remainder := jsonPathKey(rest[1:])
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath %q: missing name after .", expr)
+			}
+			path = append(path, jsonStep{key: key, wildcard: key == "*"})
+			rest = remainder
+		case strings.HasPrefix(rest, "["):
+			end := predicateEnd(rest) // Skips a ] inside a quoted name
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unclosed [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, jsonStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, jsonStep{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: unsupported subscript [%s]", expr, inner)
+				}
+				path = append(path, jsonStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, rest)
+		}
+	}
+	return path, nil
+}
+
+// jsonPathKey splits a member name off the front of s
+func jsonPathKey(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// eval returns every value the path selects from doc. Array elements come
+// in order; object members, which Go maps don't keep in document order,
+// come sorted by name.
+func (p jsonPath) eval(doc any) []any {
+	values := []any{doc}
+	for _, step := range p {
+		var next []any
+		for _, value := range values {
+			next = append(next, step.apply(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (s jsonStep) apply(value any) []any {
+	if s.recursive {
+		var found []any
+		walkJSON(value, func(v any) {
+			found = append(found, jsonStep{key: s.key, wildcard: s.wildcard}.apply(v)...)
+		})
+		return found
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		if s.wildcard {
+			found := make([]any, 0, len(v))
+			for _, key := range sortedKeys(v) {
+				found = append(found, v[key])
+			}
+			return found
+		}
+		if member, ok := v[s.key]; ok && !s.isIndex {
+			return []any{member}
+		}
+	case []any:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []any{v[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// walkJSON calls visit for value and everything nested in it
+func walkJSON(value any, visit func(any)) {
+	visit(value)
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			walkJSON(v[key], visit)
+		}
+	case []any:
+		for _, element := range v {
+			walkJSON(element, visit)
+		}
+	}
+}
+
+// sortedKeys returns the member names of an object in order, so results
+// don't depend on map iteration
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonText renders a selected JSON value as rule text; null selects nothing
+func jsonText(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
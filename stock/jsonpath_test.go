@@ -0,0 +1,89 @@
+package stock
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+const jsonPathDoc = `{
+	"product": {"sku": 6614151, "name": "RTX 5090", "available": true, "discontinued": null,
+		"a.b": "dotted", "x]y": "bracketed", "it's": "quoted"},
+	"offers": [
+		{"seller": "Newegg", "price": {"amount": 1999.99, "currency": "USD"}},
+		{"seller": "Vendor", "price": {"amount": 2499, "currency": "USD"}, "used": true}
+	],
+	"meta": {"zeta": {"price": 1}, "alpha": {"price": 2}}
+}`
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(jsonPathDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.product.name", []string{"RTX 5090"}},
+		{"$.product.sku", []string{"6614151"}},
+		{"$.product.available", []string{"true"}},
+		{"$.product.discontinued", nil}, // null selects nothing
+		{"$.product.missing", nil},
+		{"$['product']['name']", []string{"RTX 5090"}},
+		{`$["product"].name`, []string{"RTX 5090"}},
+		{"$.product['a.b']", []string{"dotted"}},
+		{`$.product["x]y"]`, []string{"bracketed"}},
+		{`$.product["it's"]`, []string{"quoted"}},
+		{"$.offers[0].seller", []string{"Newegg"}},
+		{"$.offers[-1].seller", []string{"Vendor"}},
+		{"$.offers[2].seller", nil},
+		{"$.offers[-3].seller", nil},
+		{"$.offers[*].price.amount", []string{"1999.99", "2499"}},
+		{"$.offers.*.seller", []string{"Newegg", "Vendor"}},
+		{"$.offers[1].used", []string{"true"}},
+		{"$.offers[0]", []string{`{"price":{"amount":1999.99,"currency":"USD"},"seller":"Newegg"}`}},
+		{"$.product.name[0]", nil},
+		{"$.offers.seller", nil},
+
+		// Recursive descent visits object members sorted by name
+		{"$..amount", []string{"1999.99", "2499"}},
+		{"$.meta..price", []string{"2", "1"}},
+		{"$.meta.*.price", []string{"2", "1"}},
+		{"$..currency", []string{"USD", "USD"}},
+	}
+	for _, tt := range tests {
+		path, err := compileJSONPath(tt.expr)
+		if err != nil {
+			t.Errorf("compileJSONPath(%q): %v", tt.expr, err)
+			continue
+		}
+		var got []string
+		for _, value := range path.eval(doc) {
+			if text, ok := jsonText(value); ok {
+				got = append(got, text)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"product.name",
+		"$.",
+		"$..",
+		"$.offers[",
+		"$.offers[one]",
+		"$.offers[?(@.used)]",
+		"$['a]",
+		"$ name",
+	} {
+		if _, err := compileJSONPath(expr); err == nil {
+			t.Errorf("compileJSONPath(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"gpu-sniper/config"
+	"gpu-sniper/ui"
+)
+
+// Newegg product states
+const (
+	NeweggInStock    = "in-stock"
+	NeweggOutOfStock = "out-of-stock"
+	NeweggAutoNotify = "auto-notify" // Sold out; the page offers a restock notification
+)
+
+// NeweggSeller is the seller name Newegg uses for its own stock
+const NeweggSeller = "Newegg"
+
+// NeweggUnrecognized is the indicator of a page without a buy box, such as
+// a redesigned layout or a search page a retired item redirects to
+const NeweggUnrecognized = "unrecognized page: no buy box"
+
+// NeweggPipeline is the chain of stages a Newegg item page goes through
+var NeweggPipeline = []Stage{
+	{Name: "captcha", Run: DetectNeweggBlock},
+	{Name: "debug", Run: SaveDebugPage},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParseNeweggAvailability},
+}
+
+// NeweggItem is what a Newegg item page says about the product
+type NeweggItem struct {
+	State       string  // One of the Newegg state constants
+	Price       float64 // Current price, 0 if not shown
+	Seller      string  // "Newegg" or the marketplace vendor's name
+	Marketplace bool    // Whether a marketplace vendor sells it
+	Indicator   string  // What on the page decided the state
+}
+
+// neweggSource scrapes Newegg item and combo pages
+type neweggSource struct{}
+
+func (neweggSource) Name() string { return "newegg" }
+
+func (neweggSource) Prepare(context.Context, config.Product) string { return "" }
+
+func (neweggSource) Fetch(ctx context.Context, check *Check) error {
+	req, err := Session.CreateRequest(ctx, check.Product.URL)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	ui.LogInfo("Fetching page: %s", check.Product.URL)
+	page, err := Session.FetchPage(req)
+	if err != nil {
+		return err
+	}
+	check.Page = page
+	return RunPipeline(check, NeweggPipeline)
+}
+
+// DetectNeweggBlock stops the pipeline on Newegg's bot check page
+func DetectNeweggBlock(check *Check) error {
+	doc, err := check.Page.Document()
+	if err != nil {
+		return nil // Availability parsing reports unreadable pages
+	}
+	title := strings.ToLower(doc.Find("title").First().Text())
+	if strings.Contains(title, "are you a human") || doc.Find("#px-captcha, .g-recaptcha").Length() > 0 {
+		check.Outcome.Outcome = OutcomeCaptcha
+		return ErrCaptchaDetected
+	}
+	return nil
+}
+
+// ParseNeweggAvailability decides availability from a Newegg item page
+func ParseNeweggAvailability(check *Check) error {
+	ui.LogInfo("Analyzing Newegg item page...")
+	doc, err := check.Page.Document()
+	if err != nil {
+		return fmt.Errorf("failed to parse product page: %w", err)
+	}
+	item := ParseNeweggItem(doc)
+	if item.Indicator == NeweggUnrecognized {
+		ui.LogWarning("Newegg page for %s has no buy box; treating it as out of stock", check.Product.ID)
+	} else if item.Marketplace {
+		ui.LogInfo("Newegg: %s, sold by marketplace vendor %s", item.State, item.Seller)
+	} else {
+		ui.LogInfo("Newegg: %s, sold by Newegg", item.State)
+	}
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   item.State == NeweggInStock,
+		Indicator: item.Indicator,
+		CheckedAt: check.Page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Price:     item.Price,
+		Currency:  "USD",
+		Seller:    item.Seller,
+		CartURL:   NeweggCartURL(check.Product.ID),
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// ParseNeweggItem reads the state, price and seller from an item or combo
+// page. Pages without a buy box are out of stock with NeweggUnrecognized.
+func ParseNeweggItem(doc *goquery.Document) NeweggItem {
+	item := NeweggItem{State: NeweggOutOfStock, Seller: NeweggSeller}
+
+	// The buy box button decides first: "Add to cart" or "Auto Notify"
+	buyBox := doc.Find(".product-buy, #ProductBuy, .combo-buy, .item-actions").First()
+	if buyBox.Length() == 0 {
+		// Buttons elsewhere belong to other products, e.g. recommendations
+		item.Indicator = NeweggUnrecognized
+		return item
+	}
+	buyBox.Find("button, a.btn, input[type=submit]").EachWithBreak(func(_ int, button *goquery.Selection) bool {
+		label := strings.ToLower(strings.TrimSpace(button.Text() + " " + button.AttrOr("value", "")))
+		_, disabled := button.Attr("disabled")
+		switch {
+		case strings.Contains(label, "auto notify"):
+			item.State, item.Indicator = NeweggAutoNotify, "button:contains('Auto Notify')"
+			return false
+		case strings.Contains(label, "add to cart") && !disabled:
+			item.State, item.Indicator = NeweggInStock, "button:contains('Add to cart')"
+			return false
+		}
+		return true
+	})
+
+	// Otherwise fall back to the inventory line
+	if item.Indicator == "" {
+		inventory := strings.ToLower(strings.TrimSpace(doc.Find(".product-inventory, .combo-inventory").First().Text()))
+		switch {
+		case strings.Contains(inventory, "out of stock"), strings.Contains(inventory, "sold out"):
+			item.State, item.Indicator = NeweggOutOfStock, "inventory: "+inventory
+		case strings.Contains(inventory, "in stock"):
+			item.State, item.Indicator = NeweggInStock, "inventory: "+inventory
+		}
+	}
+
+	// "Sold by: Newegg" or "Sold and Shipped by: <vendor>"
+	seller := strings.TrimSpace(doc.Find(".product-seller strong, .product-seller a").First().Text())
+	if seller == "" {
+		text := doc.Find(".product-seller").First().Text()
+		if i := strings.LastIndex(text, "by:"); i >= 0 {
+			seller = strings.TrimSpace(text[i+len("by:"):])
+		}
+	}
+	if seller != "" {
+		item.Seller = strings.Join(strings.Fields(seller), " ")
+	}
+	item.Marketplace = !strings.EqualFold(item.Seller, NeweggSeller)
+
+	// Prices are split as <strong>1,999</strong><sup>.99</sup>
+	price := doc.Find(".product-price .price-current, .product-buy-box .price-current, .combo-price .price-current").First()
+	if text := strings.TrimSpace(price.Find("strong").Text() + price.Find("sup").Text()); text != "" {
+		item.Price, _ = ParsePrice(text, config.Marketplaces[0])
+	} else if text := strings.TrimSpace(price.Text()); text != "" {
+		item.Price, _ = ParsePrice(text, config.Marketplaces[0])
+	}
+	return item
+}
+
+// NeweggCartURL returns the link that adds an item or combo to the cart
+func NeweggCartURL(itemNumber string) string {
+	return "https://secure.newegg.com/Shopping/AddtoCart.aspx?Submit=ADD&ItemList=" + url.QueryEscape(itemNumber)
+}
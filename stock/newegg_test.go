@@ -0,0 +1,55 @@
+package stock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+func TestParseNeweggItem(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    NeweggItem
+	}{
+		{"newegg_item.html", NeweggItem{State: NeweggInStock, Price: 1999.99, Seller: "Newegg", Indicator: "button:contains('Add to cart')"}},
+		{"newegg_combo.html", NeweggItem{State: NeweggInStock, Price: 1349.98, Seller: "Newegg", Indicator: "button:contains('Add to cart')"}},
+		{"newegg_marketplace.html", NeweggItem{State: NeweggInStock, Price: 3499, Seller: "GPU Resale Hub", Marketplace: true, Indicator: "button:contains('Add to cart')"}},
+		{"newegg_auto_notify.html", NeweggItem{State: NeweggAutoNotify, Price: 2499.99, Seller: "Newegg", Indicator: "button:contains('Auto Notify')"}},
+		{"newegg_search.html", NeweggItem{State: NeweggOutOfStock, Seller: "Newegg", Indicator: NeweggUnrecognized}},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(readFixture(t, tt.fixture)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ParseNeweggItem(doc); got != tt.want {
+			t.Errorf("%s: ParseNeweggItem = %+v, want %+v", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestParseNeweggAvailability(t *testing.T) {
+	product := config.Product{ID: "N82E16814126659", URL: "https://www.newegg.com/p/N82E16814126659", Retailer: config.RetailerNewegg}
+	tests := []struct {
+		fixture string
+		inStock bool
+	}{
+		{"newegg_item.html", true},
+		{"newegg_auto_notify.html", false},
+		{"newegg_search.html", false},
+	}
+	for _, tt := range tests {
+		page := httpClient.NewPage(product.URL, 200, nil, readFixture(t, tt.fixture))
+		check := &Check{Product: product, Page: page}
+		if err := RunPipeline(check, NeweggPipeline); err != nil {
+			t.Fatalf("%s: RunPipeline: %v", tt.fixture, err)
+		}
+		if check.Result.InStock != tt.inStock || check.Result.CartURL != NeweggCartURL(product.ID) {
+			t.Errorf("%s: result = %+v, want in stock %t", tt.fixture, check.Result, tt.inStock)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package stock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gpu-sniper/config"
+	"gpu-sniper/ui"
+)
+
+// NVIDIAInventory is the Founders Edition inventory API response
+type NVIDIAInventory struct {
+	Success bool              `json:"success"`
+	ListMap []NVIDIAListEntry `json:"listMap"`
+}
+
+// NVIDIAListEntry is the inventory of one SKU in one locale
+type NVIDIAListEntry struct {
+	IsActive   string `json:"is_active"` // "true" while the card can be bought
+	ProductURL string `json:"product_url"`
+	Price      string `json:"price"`
+	FESKU      string `json:"fe_sku"` // SKU with a locale suffix, e.g. "NVGFT590_US"
+	Locale     string `json:"locale"`
+}
+
+// nvidiaCurrencies maps store locales to their currency
+var nvidiaCurrencies = map[string]string{
+	"en-us": "USD", "en-ca": "CAD", "en-gb": "GBP", "de-de": "EUR", "fr-fr": "EUR",
+	"it-it": "EUR", "es-es": "EUR", "nl-nl": "EUR", "de-at": "EUR", "fr-be": "EUR",
+	"pl-pl": "PLN", "sv-se": "SEK", "da-dk": "DKK", "fi-fi": "EUR", "ja-jp": "JPY",
+}
+
+// nvidiaMarket describes how the store formats prices in a locale: English
+// and Japanese stores use a decimal point, the others a decimal comma
+func nvidiaMarket(locale string) config.Marketplace {
+	market := config.Marketplace{Currency: nvidiaCurrencies[locale], Decimal: ','}
+	if strings.HasPrefix(locale, "en-") || locale == "ja-jp" {
+		market.Decimal = '.'
+	}
+	return market
+}
+
+// NVIDIAPipeline turns an inventory response into a result
+var NVIDIAPipeline = []Stage{
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParseNVIDIAInventory},
+}
+
+// nvidiaSource polls the Founders Edition inventory endpoint
+type nvidiaSource struct{}
+
+func (nvidiaSource) Name() string { return "nvidia" }
+
+func (nvidiaSource) Prepare(context.Context, config.Product) string { return "" }
+
+func (nvidiaSource) Fetch(ctx context.Context, check *Check) error {
+	query := url.Values{"skus": {check.Product.ID}, "locale": {check.Product.Locale}}
+	endpoint := config.NVIDIAInventoryURL + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", Session.UserAgent)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	req.Header.Set("Origin", "https://marketplace.nvidia.com")
+	req.Header.Set("Referer", "https://marketplace.nvidia.com/")
+
+	ui.LogInfo("Fetching inventory: %s", endpoint)
+	page, err := Session.FetchPage(req)
+	if err != nil {
+		return err
+	}
+	check.Page = page
+	return RunPipeline(check, NVIDIAPipeline)
+}
+
+// ParseNVIDIAInventory decides availability from the inventory entry for
+// the product's SKU
+func ParseNVIDIAInventory(check *Check) error {
+	var inventory NVIDIAInventory
+	if err := json.Unmarshal(check.Page.Body(), &inventory); err != nil {
+		return fmt.Errorf("failed to parse NVIDIA inventory: %w", err)
+	}
+	entry, ok := inventory.find(check.Product.ID, check.Product.Locale)
+	if !ok {
+		return Permanent(fmt.Errorf("NVIDIA inventory has no entry for SKU %s in locale %s", check.Product.ID, check.Product.Locale))
+	}
+
+	active := strings.EqualFold(strings.TrimSpace(entry.IsActive), "true")
+	ui.LogInfo("NVIDIA: %s is_active=%s", entry.FESKU, entry.IsActive)
+	market := nvidiaMarket(check.Product.Locale)
+	price, ok := ParsePrice(entry.Price, market)
+	if !ok && strings.TrimSpace(entry.Price) != "" {
+		ui.LogWarning("NVIDIA: could not read the price %q; reporting no price", entry.Price)
+	}
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   active,
+		Indicator: "is_active=" + entry.IsActive,
+		CheckedAt: check.Page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Price:     price,
+		Currency:  market.Currency,
+		CartURL:   entry.ProductURL,
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// find returns the entry for sku in locale, matching the locale-suffixed
+// fe_sku. Entries for another locale are skipped.
+func (inv NVIDIAInventory) find(sku, locale string) (NVIDIAListEntry, bool) {
+	for _, entry := range inv.ListMap {
+		feSKU := strings.ToUpper(entry.FESKU)
+		if (feSKU == sku || strings.HasPrefix(feSKU, sku+"_")) && nvidiaLocaleMatches(entry.Locale, locale) {
+			return entry, true
+		}
+	}
+	if len(inv.ListMap) == 1 && inv.ListMap[0].FESKU == "" && nvidiaLocaleMatches(inv.ListMap[0].Locale, locale) {
+		return inv.ListMap[0], true
+	}
+	return NVIDIAListEntry{}, false
+}
+
+// nvidiaLocaleMatches reports whether an entry's locale, either the store
+// locale ("en-us") or its country ("US"), is locale. Entries without one
+// match any locale.
+func nvidiaLocaleMatches(entryLocale, locale string) bool {
+	if entryLocale == "" || strings.EqualFold(entryLocale, locale) {
+		return true
+	}
+	_, country, _ := strings.Cut(locale, "-")
+	return country != "" && strings.EqualFold(entryLocale, country)
+}
@@ -0,0 +1,85 @@
+package stock
+
+import (
+	"errors"
+	"testing"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+func TestParseNVIDIAInventory(t *testing.T) {
+	body := readFixture(t, "nvidia_inventory.json")
+	tests := []struct {
+		sku, locale string
+		inStock     bool
+		price       float64
+		currency    string
+		permanent   bool // No entry for the SKU in the locale
+	}{
+		{"NVGFT590", "en-us", true, 1999, "USD", false},
+		{"NVGFT580", "en-us", false, 999, "USD", false},
+		{"NVGFT590", "de-de", true, 2329, "EUR", false},
+		{"NVGFT571", "en-us", true, 0, "USD", false}, // Price not announced yet
+		{"NVGFT570", "en-us", false, 0, "", true},    // Only sold in Germany
+		{"NVGFT999", "en-us", false, 0, "", true},
+	}
+	for _, tt := range tests {
+		product := config.Product{ID: tt.sku, Locale: tt.locale, Retailer: config.RetailerNVIDIA}
+		page := httpClient.NewPage(config.NVIDIAInventoryURL, 200, nil, body)
+		check := &Check{Product: product, Page: page}
+		err := ParseNVIDIAInventory(check)
+		if got := errors.Is(err, ErrPermanent); got != tt.permanent {
+			t.Errorf("%s in %s: permanent = %t, want %t (%v)", tt.sku, tt.locale, got, tt.permanent, err)
+			continue
+		}
+		if tt.permanent {
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s in %s: %v", tt.sku, tt.locale, err)
+			continue
+		}
+		result := check.Result
+		if result.InStock != tt.inStock || result.Price != tt.price || result.Currency != tt.currency {
+			t.Errorf("%s in %s = in stock %t at %v %s, want %t at %v %s", tt.sku, tt.locale,
+				result.InStock, result.Price, result.Currency, tt.inStock, tt.price, tt.currency)
+		}
+		if result.CartURL == "" || result.Outcome != OutcomeSuccess {
+			t.Errorf("%s in %s: cart URL %q, outcome %v", tt.sku, tt.locale, result.CartURL, result.Outcome)
+		}
+	}
+}
+
+func TestNVIDIAInventoryFind(t *testing.T) {
+	inventory := NVIDIAInventory{ListMap: []NVIDIAListEntry{
+		{FESKU: "NVGFT590_US", Locale: "US", Price: "1999.00"},
+		{FESKU: "NVGFT590_GB", Locale: "en-gb", Price: "1799.00"},
+		{FESKU: "nvgft580", Price: "999.00"},
+	}}
+	tests := []struct {
+		sku, locale string
+		price       string // Of the entry found, empty if none
+	}{
+		{"NVGFT590", "en-us", "1999.00"},
+		{"NVGFT590", "en-gb", "1799.00"},
+		{"NVGFT590", "de-de", ""},
+		{"NVGFT580", "de-de", "999.00"}, // No locale on the entry
+		{"NVGFT59", "en-us", ""},
+	}
+	for _, tt := range tests {
+		entry, ok := inventory.find(tt.sku, tt.locale)
+		if ok != (tt.price != "") || entry.Price != tt.price {
+			t.Errorf("find(%s, %s) = %+v, %t, want price %q", tt.sku, tt.locale, entry, ok, tt.price)
+		}
+	}
+
+	// A lone entry without a SKU answers the single SKU that was asked for
+	lone := NVIDIAInventory{ListMap: []NVIDIAListEntry{{Locale: "US", Price: "1999.00"}}}
+	if _, ok := lone.find("NVGFT590", "en-us"); !ok {
+		t.Error("find ignored a lone entry without a SKU")
+	}
+	if _, ok := lone.find("NVGFT590", "fr-fr"); ok {
+		t.Error("find returned a lone entry for another locale")
+	}
+}
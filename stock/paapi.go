@@ -0,0 +1,359 @@
+package stock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+	"gpu-sniper/utils"
+)
+
+// paapiResources are the item attributes requested from GetItems
+var paapiResources = []string{
+	"ItemInfo.Title",
+	"Offers.Listings.Condition",
+	"Offers.Listings.Availability.Message",
+	"Offers.Listings.Availability.Type",
+	"Offers.Listings.MerchantInfo",
+	"Offers.Listings.Price",
+}
+
+// PAAPIResponse is the part of a GetItems response the check uses
+type PAAPIResponse struct {
+	ItemsResult struct {
+		Items []PAAPIItem `json:"Items"`
+	} `json:"ItemsResult"`
+	Errors []PAAPIError `json:"Errors"`
+}
+
+// PAAPIError is an error reported for the whole request or for one item
+type PAAPIError struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message"`
+}
+
+func (e PAAPIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// PAAPIItem is one item of a GetItems response
+type PAAPIItem struct {
+	ASIN     string `json:"ASIN"`
+	ItemInfo struct {
+		Title struct {
+			DisplayValue string `json:"DisplayValue"`
+		} `json:"Title"`
+	} `json:"ItemInfo"`
+	Offers struct {
+		Listings []PAAPIListing `json:"Listings"`
+	} `json:"Offers"`
+}
+
+// PAAPIListing is one offer for an item
+type PAAPIListing struct {
+	Availability struct {
+		Type    string `json:"Type"` // "Now", "Backorder", "Preorder", "OutOfStock", ...
+		Message string `json:"Message"`
+	} `json:"Availability"`
+	Price struct {
+		Amount   float64 `json:"Amount"`
+		Currency string  `json:"Currency"`
+	} `json:"Price"`
+	MerchantInfo struct {
+		Name string `json:"Name"`
+	} `json:"MerchantInfo"`
+	Condition struct {
+		Value string `json:"Value"` // "New", "Used", "Collectible" or "Refurbished"
+	} `json:"Condition"`
+}
+
+// PAAPIPipeline turns a GetItems response into a result
+var PAAPIPipeline = []Stage{
+	{Name: "api-error", Run: CheckPAAPIError},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParsePAAPIItems},
+}
+
+// paapiSource checks Amazon ASINs through the Product Advertising API 5.0.
+// One GetItems call asks for up to config.PAAPIBatchSize ASINs; the other
+// answers are cached briefly so checks due at the same time share the call.
+// Answers are cached per marketplace, where the same ASIN can differ.
+type paapiSource struct{}
+
+func (paapiSource) Name() string { return "paapi" }
+
+func (paapiSource) Prepare(context.Context, config.Product) string { return "" }
+
+func (paapiSource) Fetch(ctx context.Context, check *Check) error {
+	// Answers from a batched request run through the same pipeline as the
+	// request's own product, so a script decides them too
+	if entry, ok := paapiCache.fresh(paapiKey(check.Product), time.Now()); ok {
+		ui.LogInfo("Using PA-API answer from a batched request %v ago", time.Since(entry.fetchedAt).Round(time.Millisecond))
+		check.Page, check.Batch = entry.page, entry.batch
+		return RunPipeline(check, PAAPIPipeline)
+	}
+
+	market := check.Product.Market()
+	asins := paapiBatch(check.Product)
+	check.Batch = asins
+	body, err := json.Marshal(map[string]any{
+		"ItemIds":     asins,
+		"ItemIdType":  "ASIN",
+		"PartnerTag":  config.PAAPIPartnerTag,
+		"PartnerType": "Associates",
+		"Marketplace": "www." + market.Domain,
+		"Resources":   paapiResources,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode PA-API request: %w", err)
+	}
+	endpoint := config.PAAPIEndpoint
+	if endpoint == "" {
+		endpoint = "https://webservices." + market.Domain + "/paapi5/getitems"
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "amz-1.0")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Amz-Target", "com.amazon.paapi5.v1.ProductAdvertisingAPIv1.GetItems")
+
+	// Sign only once the throttle lets the call through so the signature
+	// date is current
+	if err := paapiThrottle.wait(ctx); err != nil {
+		return err
+	}
+	creds := httpClient.Credentials{AccessKey: config.PAAPIAccessKey, SecretKey: config.PAAPISecretKey}
+	httpClient.SignV4(req, body, creds, market.PAAPIRegion, "ProductAdvertisingAPI", time.Now())
+
+	ui.LogInfo("Querying PA-API GetItems for %d ASIN(s): %s", len(asins), strings.Join(asins, ", "))
+	page, err := paapiClient().FetchPage(req)
+	if err != nil {
+		return err
+	}
+	check.Page = page
+	return RunPipeline(check, PAAPIPipeline)
+}
+
+// CheckPAAPIError turns request-level API errors into readable errors.
+// Throttling is left to CheckResponseStatus as rate limiting.
+func CheckPAAPIError(check *Check) error {
+	status := check.Page.StatusCode
+	if status < 400 || status >= 500 || status == http.StatusTooManyRequests {
+		return nil
+	}
+
+	var reply PAAPIResponse
+	message := http.StatusText(status)
+	if json.Unmarshal(check.Page.Body(), &reply) == nil && len(reply.Errors) > 0 {
+		message = reply.Errors[0].Code + ": " + reply.Errors[0].Message
+	}
+	return Permanent(fmt.Errorf("PA-API error %d: %s", status, message))
+}
+
+// ParsePAAPIItems caches every item and item error of a GetItems response
+// under the checked product's marketplace and decides availability for the
+// checked product
+func ParsePAAPIItems(check *Check) error {
+	var reply PAAPIResponse
+	if err := json.Unmarshal(check.Page.Body(), &reply); err != nil {
+		return fmt.Errorf("failed to parse PA-API response: %w", err)
+	}
+
+	domain := check.Product.Market().Domain
+	asins := check.Batch
+	if len(asins) == 0 {
+		asins = []string{check.Product.ID}
+	}
+	answer := paapiEntry{fetchedAt: check.Page.FetchedAt, page: check.Page, batch: asins}
+	for _, item := range reply.ItemsResult.Items {
+		entry := answer
+		entry.item = item
+		paapiCache.store(domain+"/"+item.ASIN, entry)
+	}
+	// Item errors name the ASIN only in their message, so they are matched
+	// against the ASINs of this request
+	for _, apiErr := range reply.Errors {
+		for _, asin := range asins {
+			if strings.Contains(apiErr.Message, asin) {
+				entry := answer
+				entry.err = apiErr
+				paapiCache.store(domain+"/"+asin, entry)
+			}
+		}
+	}
+
+	entry, ok := paapiCache.fresh(paapiKey(check.Product), time.Now())
+	if !ok {
+		return fmt.Errorf("PA-API response has no item for ASIN %s", check.Product.ID)
+	}
+	return entry.apply(check)
+}
+
+// paapiEntry is the answer a GetItems call gave for one ASIN
+type paapiEntry struct {
+	fetchedAt time.Time
+	item      PAAPIItem
+	err       error            // Set when the API rejected the ASIN
+	page      *httpClient.Page // The whole response, run through the pipeline again on cache hits
+	batch     []string         // ASINs the request asked for
+}
+
+// apply fills check.Result from the cached answer
+func (e paapiEntry) apply(check *Check) error {
+	if e.err != nil {
+		return Permanent(fmt.Errorf("PA-API rejected ASIN %s: %w", check.Product.ID, e.err))
+	}
+
+	result := Result{
+		ProductID: check.Product.ID,
+		Title:     e.item.ItemInfo.Title.DisplayValue,
+		Indicator: "availability=none",
+		CheckedAt: e.fetchedAt,
+		Outcome:   OutcomeSuccess,
+		Currency:  check.Product.Market().Currency,
+	}
+	for i, listing := range e.item.Offers.Listings {
+		if i == 0 || listing.Availability.Type == "Now" {
+			result.Indicator = "availability=" + listing.Availability.Type
+			result.Price = listing.Price.Amount
+			if listing.Price.Currency != "" {
+				result.Currency = listing.Price.Currency
+			}
+			result.Seller = listing.MerchantInfo.Name
+			result.Condition = strings.ToLower(listing.Condition.Value)
+		}
+		if listing.Availability.Type == "Now" {
+			result.InStock = true
+			break
+		}
+	}
+	if len(e.item.Offers.Listings) > 0 {
+		ui.LogInfo("PA-API: %s", e.item.Offers.Listings[0].Availability.Message)
+	} else {
+		ui.LogInfo("PA-API: no offers listed")
+	}
+
+	check.Result = result
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// paapiBatch returns the ASINs to ask for when checking product: the
+// product itself plus the other PA-API products on the same marketplace
+// whose cached answers are the oldest
+func paapiBatch(product config.Product) []string {
+	var others []config.Product
+	for _, other := range config.Products {
+		if other.Source == config.SourcePAAPI && other.ID != product.ID && other.Market().Domain == product.Market().Domain {
+			others = append(others, other)
+		}
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return paapiCache.fetchedAt(paapiKey(others[i])).Before(paapiCache.fetchedAt(paapiKey(others[j])))
+	})
+
+	asins := []string{product.ID}
+	for _, other := range others {
+		if len(asins) == config.PAAPIBatchSize {
+			break
+		}
+		if !slices.Contains(asins, other.ID) {
+			asins = append(asins, other.ID)
+		}
+	}
+	return asins
+}
+
+// paapiKey is the cache key of a product: its marketplace domain and ASIN
+func paapiKey(product config.Product) string {
+	return product.Market().Domain + "/" + product.ID
+}
+
+// paapiAnswers caches GetItems answers by paapiKey
+type paapiAnswers struct {
+	mu      sync.Mutex
+	entries map[string]paapiEntry
+}
+
+var paapiCache = &paapiAnswers{entries: make(map[string]paapiEntry)}
+
+// store caches entry under key unless a newer answer is cached already, as
+// when an older response is parsed again for a cache hit
+func (c *paapiAnswers) store(key string, entry paapiEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.entries[key]; ok && cached.fetchedAt.After(entry.fetchedAt) {
+		return
+	}
+	c.entries[key] = entry
+}
+
+// fresh returns the answer for key if it is younger than config.PAAPICacheTTL
+func (c *paapiAnswers) fresh(key string, now time.Time) (paapiEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || now.Sub(entry.fetchedAt) > config.PAAPICacheTTL {
+		return paapiEntry{}, false
+	}
+	return entry, true
+}
+
+// fetchedAt returns when key was last answered, zero if never
+func (c *paapiAnswers) fetchedAt(key string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key].fetchedAt
+}
+
+// paapiThrottle spaces PA-API calls at least config.PAAPIMinInterval apart,
+// independently of the page-scraping rate limits
+var paapiThrottle = &throttle{interval: config.PAAPIMinInterval}
+
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the caller's turn, giving up when ctx is done
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(t.interval)
+	t.mu.Unlock()
+	return utils.Sleep(ctx, at.Sub(now))
+}
+
+var (
+	paapiOnce    sync.Once
+	paapiSession *httpClient.Session
+)
+
+// paapiClient returns a session without the retailer cookies, which have no
+// business being sent to the API
+func paapiClient() *httpClient.Session {
+	paapiOnce.Do(func() {
+		paapiSession = &httpClient.Session{
+			Client:    &http.Client{Timeout: Session.Client.Timeout, Transport: Session.Client.Transport},
+			UserAgent: Session.UserAgent,
+		}
+	})
+	return paapiSession
+}
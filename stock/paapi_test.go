@@ -0,0 +1,81 @@
+package stock
+
+import (
+	"context"
+	"testing"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+func TestParsePAAPIItems(t *testing.T) {
+	paapiCache = &paapiAnswers{entries: make(map[string]paapiEntry)}
+	us := config.Product{ID: "B0DT7L98J1", Retailer: config.RetailerAmazon, Source: config.SourcePAAPI}
+	de := us
+	de.Marketplace = "amazon.de"
+	other := config.Product{ID: "B0DT7JXQ4K", Retailer: config.RetailerAmazon, Source: config.SourcePAAPI}
+	unasked := config.Product{ID: "B0DS2X13PH", Retailer: config.RetailerAmazon, Source: config.SourcePAAPI}
+
+	// amazon.de answers for B0DT7L98J1 and rejects B0DT7JXQ4K. The error
+	// also names B0DS2X13PH, which this request did not ask for.
+	body := `{"ItemsResult":{"Items":[{"ASIN":"B0DT7L98J1","Offers":{"Listings":[
+		{"Availability":{"Type":"Now"},"Price":{"Amount":2329.0,"Currency":"EUR"},"MerchantInfo":{"Name":"Amazon"}}]}}]},
+		"Errors":[{"Code":"ItemNotAccessible","Message":"The ItemId B0DT7JXQ4K is not accessible through the Product Advertising API; see B0DS2X13PH."}]}`
+	page := httpClient.NewPage("https://webservices.amazon.de/paapi5/getitems", 200, nil, []byte(body))
+	check := &Check{Product: de, Page: page, Batch: []string{de.ID, other.ID}}
+	if err := ParsePAAPIItems(check); err != nil {
+		t.Fatalf("ParsePAAPIItems: %v", err)
+	}
+	if !check.Result.InStock || check.Result.Price != 2329 || check.Result.Currency != "EUR" {
+		t.Fatalf("result = %+v, want in stock at 2329 EUR", check.Result)
+	}
+
+	now := page.FetchedAt
+	if _, ok := paapiCache.fresh(paapiKey(us), now); ok {
+		t.Errorf("the amazon.de answer is cached for amazon.com")
+	}
+	other.Marketplace = "amazon.de"
+	if entry, ok := paapiCache.fresh(paapiKey(other), now); !ok || entry.err == nil {
+		t.Errorf("the error for %s was not cached: %+v", other.ID, entry)
+	}
+	unasked.Marketplace = "amazon.de"
+	if _, ok := paapiCache.fresh(paapiKey(unasked), now); ok {
+		t.Errorf("an error was cached for %s, which the request did not ask for", unasked.ID)
+	}
+}
+
+func TestPAAPICacheHitRunsScript(t *testing.T) {
+	paapiCache = &paapiAnswers{entries: make(map[string]paapiEntry)}
+	script, err := loadTestScript(t, `
+def check(response):
+    return {"in_stock": False, "indicator": "script"}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productScripts = map[string]*productScript{script.path: script}
+	t.Cleanup(func() { productScripts = map[string]*productScript{} })
+
+	fetched := config.Product{ID: "B0DT7L98J1", Retailer: config.RetailerAmazon, Source: config.SourcePAAPI}
+	batched := config.Product{ID: "B0DT7JXQ4K", Retailer: config.RetailerAmazon, Source: config.SourcePAAPI, Script: script.path}
+
+	// The request for the first product also answers the second
+	body := `{"ItemsResult":{"Items":[
+		{"ASIN":"B0DT7L98J1","Offers":{"Listings":[{"Availability":{"Type":"Now"},"Price":{"Amount":1999.0,"Currency":"USD"}}]}},
+		{"ASIN":"B0DT7JXQ4K","Offers":{"Listings":[{"Availability":{"Type":"Now"},"Price":{"Amount":999.0,"Currency":"USD"}}]}}]}}`
+	page := httpClient.NewPage("https://webservices.amazon.com/paapi5/getitems", 200, nil, []byte(body))
+	if err := RunPipeline(&Check{Product: fetched, Page: page, Batch: []string{fetched.ID, batched.ID}}, PAAPIPipeline); err != nil {
+		t.Fatalf("RunPipeline: %v", err)
+	}
+
+	check := &Check{Product: batched}
+	if err := (paapiSource{}).Fetch(context.Background(), check); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if check.Result.InStock || check.Result.Indicator != "script" {
+		t.Errorf("cached answer = %+v, want the script's out of stock", check.Result)
+	}
+	if check.Page != page {
+		t.Errorf("the script did not see the batched response")
+	}
+}
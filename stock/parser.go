@@ -1,17 +1,16 @@
 package stock
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"math/rand"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
-
 	"gpu-sniper/config"
 	httpClient "gpu-sniper/http"
 	"gpu-sniper/ui"
@@ -21,15 +20,32 @@ import (
 // Global variable to track the current progress tracker
 var CurrentProgressTracker *ui.ProgressTracker
 
-// Custom error type for CAPTCHA detection
-var ErrCaptchaDetected = fmt.Errorf("CAPTCHA challenge detected")
+// ErrCaptchaDetected is returned when the retailer serves a block page
+var ErrCaptchaDetected = utils.ErrCaptchaDetected
 
 // ParseStockStatus parses an HTTP response to check if the product is in stock
 func ParseStockStatus(resp *http.Response) (bool, error) {
-    // Parse directly from response body
-    doc, err := goquery.NewDocumentFromReader(resp.Body)
-    if (err != nil) {
-        return false, fmt.Errorf("error parsing HTML: %w", err)
+    page, err := httpClient.PageFromResponse(resp)
+    if err != nil {
+        return false, err
+    }
+    // Responses built by hand may have no request; use the default storefront
+    market := config.Marketplaces[0]
+    if resp.Request != nil && resp.Request.URL != nil {
+        if m, ok := config.MarketplaceFor(resp.Request.URL.Host); ok {
+            market = m
+        }
+    }
+    inStock, _, err := parsePage(page, market)
+    return inStock, err
+}
+
+// parsePage looks for add-to-cart and out-of-stock indicators and returns
+// the availability together with the indicator that decided it
+func parsePage(page *httpClient.Page, market config.Marketplace) (bool, string, error) {
+    doc, err := page.Document()
+    if err != nil {
+        return false, "", err
     }
     
     // Primary check - exact ID match
@@ -39,7 +55,7 @@ func ParseStockStatus(resp *http.Response) (bool, error) {
         _, disabled := addToCartButton.Attr("disabled")
         if !disabled {
             ui.LogInfo("Found enabled add-to-cart button with ID 'add-to-cart-button'")
-            return true, nil
+            return true, "#add-to-cart-button", nil
         } else {
             ui.LogInfo("Add-to-cart button found but is disabled")
         }
@@ -52,163 +68,153 @@ func ParseStockStatus(resp *http.Response) (bool, error) {
         "[id*=addToCart]",
         "[class*=addToCart]",
         ".btn-add-to-cart:not([disabled])",
-        "button:contains('Add to Cart')",
-        "input[type=submit][value*='Add to Cart']",
+    }
+    for _, label := range market.AddToCart {
+        selectors = append(selectors,
+            fmt.Sprintf("button:contains('%s')", label),
+            fmt.Sprintf("input[type=submit][value*='%s']", label))
     }
     
     for _, selector := range selectors {
         elements := doc.Find(selector)
         if elements.Length() > 0 {
             ui.LogInfo("Found alternative add-to-cart element with selector: %s", selector)
-            return true, nil
+            return true, selector, nil
         }
     }
     
     // Additional check for "Out of Stock" text which indicates item exists but is unavailable
-    outOfStockTexts := []string{"Out of Stock", "Sold Out", "Currently unavailable", "Temporarily out of stock"}
-    for _, text := range outOfStockTexts {
+    for _, text := range market.OutOfStock {
         if doc.Find(fmt.Sprintf("*:contains('%s')", text)).Length() > 0 {
             ui.LogInfo("Page contains '%s' text, confirming item exists but is out of stock", text)
-            return false, nil
+            return false, text, nil
         }
     }
     
     ui.LogInfo("No add-to-cart indicators found on the page")
-    return false, nil
+    return false, "", nil
 }
 
-func AdjustPollingByTimeOfDay() time.Duration {
-    hour := time.Now().Hour()
-    
-    // Late night hours (fewer checks needed)
-    if hour >= 1 && hour < 6 {
-        return config.PollingInterval * 2
-    }
-    
-    // High traffic hours (be more cautious)
-    if (hour >= 12 && hour <= 14) || (hour >= 18 && hour <= 21) {
-        return config.PollingInterval + time.Duration(rand.Int63n(int64(30*time.Second)))
-    }
-    
-    return config.PollingInterval
+// Session is the retailer session used for stock checks; main opens it
+// before the first check
+var Session *httpClient.Session
+
+// Polling adapts the interval per host from check outcomes; replace it to
+// change the adaptive polling strategy
+var Polling PollingController = NewAIMDController(systemClock{})
+
+var (
+	schedulesMu sync.Mutex
+	schedules   = make(map[string]*ScheduleController)
+)
+
+// ScheduleFor returns the schedule-aware polling controller for a product
+func ScheduleFor(product config.Product) *ScheduleController {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+
+	if controller, ok := schedules[product.Key()]; ok {
+		return controller
+	}
+	schedule := config.DefaultSchedule
+	if product.Schedule != nil {
+		schedule = *product.Schedule
+	}
+	controller := NewScheduleController(Polling, schedule, product.Location(), systemClock{})
+	schedules[product.Key()] = controller
+	return controller
 }
 
-func GetNextPollingInterval() time.Duration {
-    baseInterval := AdjustPollingByTimeOfDay()
-    jitter := time.Duration(rand.Int63n(int64(5 * time.Second)))
-    return baseInterval + jitter
+var (
+	intervalsMu sync.Mutex
+	intervals   = make(map[string]time.Duration) // Latest polling interval by product key
+)
+
+// GetNextPollingInterval returns the delay before the next check of a product, with jitter
+func GetNextPollingInterval(product config.Product) time.Duration {
+	host := hostOf(product.URL)
+	interval := ScheduleFor(product).NextInterval(host, config.DefaultPollingInterval)
+
+	// Resume only once the host's cooling-down period is over
+	if remaining := cooldownFor(host).remaining(time.Now()); remaining > interval {
+		interval = remaining
+	}
+	intervalsMu.Lock()
+	intervals[product.Key()] = interval
+	intervalsMu.Unlock()
+
+	jitter := time.Duration(rand.Int63n(int64(5 * time.Second)))
+	return interval + jitter
 }
 
 // SetProgressTracker updates the current progress tracker
 func SetProgressTracker(tracker *ui.ProgressTracker) {
 	CurrentProgressTracker = tracker
-}
 
-// UpdatePollingInterval updates the current polling interval and progress tracker if available
-func UpdatePollingInterval(newInterval time.Duration) {
-    config.PollingInterval = newInterval
-    
-    // If we have an active progress tracker, update it
-    if CurrentProgressTracker != nil {
-        CurrentProgressTracker.UpdateDuration(newInterval)
-        ui.LogInfo("Polling interval updated to %v due to rate limiting", newInterval)
-    }
+	// Keep the cooling-down state visible across countdowns
+	if tracker != nil {
+		for host, c := range activeCooldowns(time.Now()) {
+			tracker.UpdateStatus(cooldownStatusText(host, c.Until))
+		}
+	}
 }
 
 // CheckStock performs a stock check and analyzes the results with retry logic
-func CheckStock() bool {
+func CheckStock(ctx context.Context, product config.Product) Result {
 	// Update check counter
 	config.CheckCount++
 	config.LastCheckTime = time.Now()
+	result := Result{ProductID: product.ID, CheckedAt: config.LastCheckTime}
 	
 	// Update status in the tracker if available
 	if CurrentProgressTracker != nil {
 		CurrentProgressTracker.UpdateStatus("Checking")
 	}
 	
-	httpClient.VisitRelatedPage()
+	// Don't hit the site at all while it is cooling down after a CAPTCHA
+	host := hostOf(product.URL)
+	cooldown := cooldownFor(host)
+	if remaining := cooldown.remaining(time.Now()); remaining > 0 {
+		until, _ := cooldown.snapshot()
+		ui.LogWarning("Cooling down after CAPTCHA on %s, skipping check #%d (resumes in %v)",
+			host, config.CheckCount, remaining.Round(time.Second))
+		if CurrentProgressTracker != nil {
+			CurrentProgressTracker.UpdateStatus(cooldownStatusText(host, until))
+		}
+		result.Outcome = OutcomeSkipped
+		return result
+	}
+
+	source := SourceFor(product)
+	location := source.Prepare(ctx, product)
+	result.Location = location
 	
 	// Clear the progress bar line and print header
 	fmt.Printf("\r\033[K")
-	config.HeaderColor.Printf("\n[STOCK CHECK #%d] %s\n", config.CheckCount, time.Now().Format("2006-01-02 03:04:05 PM"))
+	config.HeaderColor.Printf("\n[STOCK CHECK #%d] %s - %s\n", config.CheckCount, product.DisplayName(), time.Now().Format("2006-01-02 03:04:05 PM"))
+	if location != "" {
+		ui.LogInfo("Delivery location: %s", location)
+	}
+	if window, ok := ScheduleFor(product).ActiveWindow(); ok && window != "" {
+		ui.LogInfo("Schedule window: %s", window)
+	}
 	fmt.Println(strings.Repeat("─", 50))
 
-	var inStock bool
-	var captchaDetected bool
-
-	operation := func() error {
-		// Create and send HTTP request
-		req, err := httpClient.CreateRequest()
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
-
-		ui.LogInfo("Fetching page: %s", config.RetailerURL)
-		resp, err := httpClient.HttpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("network error: failed to fetch page. Please check your internet connection: %w", err)
-		}
-
-		if isCaptchaPage(resp) {
-            ui.LogWarning("CAPTCHA detected - increasing delay and implementing cooling period")
-            
-            // Apply exponential backoff to polling interval
-            newInterval := config.PollingInterval * 3
-            if newInterval > 15*time.Minute {
-                newInterval = 15*time.Minute
-            }
-            UpdatePollingInterval(newInterval)
-            
-            // Update progress tracker
-            if CurrentProgressTracker != nil {
-                CurrentProgressTracker.UpdateStatus("Cooling Down - CAPTCHA detected")
-            }
-            
-            captchaDetected = true
-            return ErrCaptchaDetected
-        }
-
-		DebugSaveHTML(resp)
-
-		defer resp.Body.Close()
+	outcome := CheckOutcome{Host: host}
 
-		// Updated error messages for HTTP failures
-		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden {
-			ui.LogWarning("HTTP %d received, indicating rate limiting. Please wait and check your connection.", resp.StatusCode)
-			// Modify the polling interval temporarily
-			newInterval := config.PollingInterval * 2
-			if newInterval > 5*time.Minute {
-				newInterval = 5 * time.Minute
-			}
-			UpdatePollingInterval(newInterval)
-			
-			if CurrentProgressTracker != nil {
-				CurrentProgressTracker.UpdateStatus("Rate limited")
-			}
-			
-			return fmt.Errorf("HTTP %d: rate limiting in effect. Too many requests; please try again later", resp.StatusCode)
-		} else if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("HTTP error %d: failed to fetch the product page. Verify your network connection or check if the website is experiencing issues", resp.StatusCode)
-		} else {
-			// Gradually reset polling interval on successful requests
-			if config.PollingInterval > config.DefaultPollingInterval {
-				newInterval := time.Duration(float64(config.PollingInterval) * 0.8)
-				if newInterval < config.DefaultPollingInterval {
-					newInterval = config.DefaultPollingInterval
-				}
-				UpdatePollingInterval(newInterval)
-			}
-		}
-		ui.LogSuccess("Page fetched successfully")
+	operation := func(ctx context.Context) error {
+		outcome.Outcome = OutcomeError
+		outcome.Hints = httpClient.ServerHints{}
 
-		// Parse the response directly
-		ui.LogInfo("Analyzing product availability...")
-		stockStatus, err := ParseStockStatus(resp)
+		// Let the product's source fetch and decide availability
+		check := &Check{Product: product, Outcome: outcome, Result: Result{Location: location}}
+		err := source.Fetch(ctx, check)
+		outcome = check.Outcome
 		if err != nil {
-			return fmt.Errorf("failed to parse product page: %w", err)
+			return err
 		}
-		
-		inStock = stockStatus
+		result = check.Result
+		result.ProductID = product.ID
 		
 		// After successful check, update status
 		if CurrentProgressTracker != nil {
@@ -218,84 +224,75 @@ func CheckStock() bool {
 		return nil
 	}
 	
-	var err error
-	// Choose the appropriate retry configuration based on the situation
-	if captchaDetected {
-		// Use CaptchaRetryConfig for CAPTCHA-related retries
-		err = utils.RetryOperation(operation, config.CaptchaRetryConfig)
-	} else {
-		// Use StockCheckRetryConfig for normal stock checks
-		err = utils.RetryOperation(operation, config.StockCheckRetryConfig)
-	}
+	// A CAPTCHA aborts the retry loop immediately and switches to cooling down
+	// Server-requested delays longer than the retry backoff are left to the scheduler
+	policy := utils.PolicyFromConfig(config.StockCheckRetryConfig)
+	policy.MaxRetryAfter = config.StockCheckRetryConfig.MaxBackoff
+	policy.Rules = append([]utils.RetryRule{
+		utils.ForError("captcha", ErrCaptchaDetected, utils.NoRetry{}),
+		utils.ForError("permanent", ErrPermanent, utils.NoRetry{}),
+	}, policy.Rules...)
+
+	err := utils.Retry(ctx, operation, policy)
 	
 	fmt.Println(strings.Repeat("─", 50))
+
+	// An aborted check says nothing about the site
+	if ctx.Err() != nil {
+		ui.LogWarning("Stock check for %s cancelled", product.DisplayName())
+		result.Outcome = OutcomeCancelled
+		result.Err = err
+		return result
+	}
+
+	// Let the polling controller adapt to how the check went
+	Polling.Observe(outcome)
+	result.Outcome = outcome.Outcome
+	result.Err = err
 	
-	if err == ErrCaptchaDetected {
-		ui.LogWarning("CAPTCHA detected, cooling down for an extended period")
-		return false
+	if errors.Is(err, ErrCaptchaDetected) {
+		period := cooldown.enter(time.Now())
+		until, strikes := cooldown.snapshot()
+		ui.LogWarning("CAPTCHA detected (strike %d), pausing checks of %s for %v until %s",
+			strikes, host, period, until.Format("03:04:05 PM"))
+		if CurrentProgressTracker != nil {
+			CurrentProgressTracker.UpdateStatus(cooldownStatusText(host, until))
+		}
+		return result
 	}
 
 	if err != nil {
 		ui.LogError("Stock check failed after retries: %v", err)
-		return false
+		return result
 	}
+
+	// A clean check ends any CAPTCHA escalation
+	cooldown.reset()
 	
 	// Reset status to waiting if we have a tracker
 	if CurrentProgressTracker != nil {
 		CurrentProgressTracker.UpdateStatus("Waiting")
 	}
 
-	if inStock {
-		config.SuccessColor.Printf("✓ %s is IN STOCK!\n", config.TargetGPU)
-		return true
+	where := ""
+	if result.Location != "" {
+		where = " for delivery to " + result.Location
+	}
+	if result.Price > 0 {
+		ui.LogInfo("Price: %s", ui.FormatPrice(result.Price, result.Currency))
+	}
+	if result.InStock {
+		config.SuccessColor.Printf("✓ %s is IN STOCK%s!\n", product.DisplayName(), where)
 	} else {
-		config.ErrorColor.Printf("✗ %s is not in stock\n", config.TargetGPU)
-		return false
+		config.ErrorColor.Printf("✗ %s is not in stock%s\n", product.DisplayName(), where)
 	}
+	return result
 }
 
-func DebugSaveHTML(resp *http.Response) error {
-    // Clone the body since reading it consumes it
-    bodyBytes, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return err
-    }
-    
-    // Restore the body for other functions
-    resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-    
-    // Save HTML to file
-    filename := fmt.Sprintf("debug_%s.html", time.Now().Format("20060102_150405"))
-    err = os.WriteFile(filename, bodyBytes, 0644)
-    if err != nil {
-        return err
-    }
-    
-    ui.LogInfo("Saved HTML content to %s for debugging", filename)
-    return nil
-}
-
-func isCaptchaPage(resp *http.Response) bool {
-    bodyBytes, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return false
-    }
-    
-    // Restore the body for other operations
-    resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-    
-    // Check for CAPTCHA indicators in the response body
-    bodyStr := string(bodyBytes)
-    captchaIndicators := []string{
-        "captcha", "robot", "characters you see", "verify human", 
-        "Enter the characters", "validateCaptcha",
-    }
-    
-    for _, indicator := range captchaIndicators {
-        if strings.Contains(strings.ToLower(bodyStr), strings.ToLower(indicator)) {
-            return true
-        }
-    }
-    
-    return false
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
 }
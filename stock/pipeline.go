@@ -0,0 +1,228 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/rules"
+	"gpu-sniper/ui"
+	"gpu-sniper/utils"
+)
+
+// Result is the availability decided for a product by a stock check
+type Result struct {
+	ProductID string    // Product that was checked
+	InStock   bool      // Whether the product can be bought
+	Indicator string    // What on the page decided the result
+	CheckedAt time.Time // When the page was fetched
+	Outcome   Outcome   // How the check ended
+	Err       error     // Why the check failed, if it did
+	Location  string    // Delivery ZIP code the page was rendered for, if any
+	Price     float64   // Buy-box price, 0 if none was found
+	Currency  string    // ISO 4217 code of Price
+	CartURL   string    // Link that adds the product to the cart, if the source provides one
+	Seller    string    // Who sells the offer, if the source reports it
+	Title     string    // Product title, if the source reports it
+	Condition string    // Offer condition ("new", "used", ...), if the source reports it
+}
+
+// Facts returns the values alert rules are evaluated on for this result
+func (r Result) Facts(product config.Product) rules.Vars {
+	state, condition := "out_of_stock", r.Condition
+	if r.InStock {
+		state = "in_stock"
+	}
+	if condition == "" {
+		condition = "new"
+	}
+	return rules.Vars{
+		"state":     state,
+		"in_stock":  r.InStock,
+		"price":     r.Price,
+		"currency":  r.Currency,
+		"seller":    r.Seller,
+		"condition": strings.ToLower(condition),
+		"title":     r.Title,
+		"location":  r.Location,
+		"product":   product.ID,
+		"retailer":  product.Retailer,
+	}
+}
+
+// ErrPermanent matches failures that retrying the same request can't fix,
+// such as an unknown product or a rejected API key
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent marks err so CheckStock does not retry it
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type permanentError struct{ error }
+
+func (e permanentError) Is(target error) bool { return target == ErrPermanent }
+func (e permanentError) Unwrap() error        { return e.error }
+
+// Check carries the state of one stock check through the pipeline stages
+type Check struct {
+	Product config.Product
+	Page    *httpClient.Page
+	Outcome CheckOutcome
+	Result  Result
+	Batch   []string // IDs a batched API request asked for, Product's included
+}
+
+// Stage is one step of the stock check pipeline; an error stops the pipeline
+type Stage struct {
+	Name string
+	Run  func(check *Check) error
+}
+
+// Pipeline is the ordered chain of stages every fetched page goes through
+var Pipeline = []Stage{
+	{Name: "captcha", Run: DetectCaptcha},
+	{Name: "debug", Run: SaveDebugPage},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: ParseAvailability},
+	{Name: "location", Run: VerifyLocation},
+}
+
+// RunPipeline passes check through each stage in order. A product script
+// takes the place of the availability stage.
+func RunPipeline(check *Check, stages []Stage) error {
+	for _, stage := range stages {
+		run := stage.Run
+		if stage.Name == "availability" && check.Product.Script != "" {
+			run = RunScript
+		}
+		if err := run(check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captchaIndicators are matched case-insensitively against block pages
+var captchaIndicators = []string{
+	"captcha", "robot", "characters you see", "verify human",
+	"enter the characters", "validatecaptcha",
+}
+
+// DetectCaptcha stops the pipeline when the retailer served a block page
+func DetectCaptcha(check *Check) error {
+	body := check.Page.LowerText()
+	for _, indicator := range captchaIndicators {
+		if strings.Contains(body, indicator) {
+			check.Outcome.Outcome = OutcomeCaptcha
+			return ErrCaptchaDetected
+		}
+	}
+	return nil
+}
+
+// SaveDebugPage writes the decoded page to disk when config.SaveDebugHTML is
+// set. Streamed pages closed early are skipped: a truncated snapshot would
+// replay differently from the check it was taken for.
+func SaveDebugPage(check *Check) error {
+	if !config.SaveDebugHTML {
+		return nil
+	}
+	if check.Page.Partial() {
+		ui.LogInfo("Not saving debug HTML: the streaming parser stopped reading early")
+		return nil
+	}
+	if err := DebugSaveHTML(check.Product.ID, check.Page); err != nil {
+		ui.LogWarning("Failed to save debug HTML: %v", err)
+	}
+	return nil
+}
+
+// CheckResponseStatus records server hints and turns error statuses into
+// errors the retry policy understands
+func CheckResponseStatus(check *Check) error {
+	page := check.Page
+
+	// Record Retry-After, maintenance and cache hints for this host
+	hints := page.Hints(time.Now())
+	check.Outcome.Hints = hints
+
+	// Updated error messages for HTTP failures
+	if page.StatusCode == http.StatusTooManyRequests || page.StatusCode == http.StatusForbidden {
+		check.Outcome.Outcome = OutcomeRateLimited
+		ui.LogWarning("HTTP %d received, indicating rate limiting. Please wait and check your connection.", page.StatusCode)
+		if hints.RetryAfter > 0 {
+			ui.LogInfo("Server asked to retry after %v", hints.RetryAfter)
+		}
+
+		if CurrentProgressTracker != nil {
+			CurrentProgressTracker.UpdateStatus("Rate limited")
+		}
+
+		return &utils.RetryAfterError{
+			Err:   fmt.Errorf("HTTP %d: rate limiting in effect. Too many requests; please try again later", page.StatusCode),
+			After: hints.RetryAfter,
+		}
+	} else if hints.Maintenance {
+		check.Outcome.Outcome = OutcomeMaintenance
+		delay := hintDelay(hints)
+		ui.LogWarning("HTTP 503 received, site is under maintenance. Next check in %v", delay.Round(time.Second))
+		if CurrentProgressTracker != nil {
+			CurrentProgressTracker.UpdateStatus("Site maintenance")
+		}
+		return &utils.RetryAfterError{
+			Err:   fmt.Errorf("HTTP 503: service unavailable, the site is under maintenance"),
+			After: delay,
+		}
+	} else if page.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error %d: failed to fetch the product page. Verify your network connection or check if the website is experiencing issues", page.StatusCode)
+	}
+
+	ui.LogSuccess("Page fetched successfully (HTTP %d in %v)",
+		page.StatusCode, page.Latency.Round(time.Millisecond))
+	return nil
+}
+
+// ParseAvailability decides whether the product is in stock
+func ParseAvailability(check *Check) error {
+	ui.LogInfo("Analyzing product availability...")
+	market := check.Product.Market()
+	inStock, indicator, err := parsePage(check.Page, market)
+	if err != nil {
+		return fmt.Errorf("failed to parse product page: %w", err)
+	}
+	var price float64
+	if doc, err := check.Page.Document(); err == nil {
+		price, _ = findPrice(doc, market)
+	}
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   inStock,
+		Indicator: indicator,
+		CheckedAt: check.Page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Location:  check.Result.Location,
+		Price:     price,
+		Currency:  market.Currency,
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// DebugSaveHTML saves the decoded page to a file named by SnapshotName,
+// readable only by the user like the cookie and session files
+func DebugSaveHTML(productID string, page *httpClient.Page) error {
+	filename := SnapshotName(productID, page.FetchedAt)
+	if err := os.WriteFile(filename, page.Body(), 0600); err != nil {
+		return err
+	}
+
+	ui.LogInfo("Saved HTML content to %s for debugging", filename)
+	return nil
+}
@@ -0,0 +1,183 @@
+package stock
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+	"gpu-sniper/utils"
+)
+
+func TestMain(m *testing.M) {
+	ui.Quiet = true
+	config.SaveDebugHTML = false
+	os.Exit(m.Run())
+}
+
+var testProduct = config.Product{
+	ID:       "B0DT7L98J1",
+	URL:      "https://www.amazon.com/dp/B0DT7L98J1",
+	Retailer: config.RetailerAmazon,
+}
+
+// readFixture returns a saved page from testdata
+func readFixture(tb testing.TB, name string) []byte {
+	tb.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return body
+}
+
+// fixtureCheck returns a check of testProduct against a saved page
+func fixtureCheck(tb testing.TB, name string, status int, header http.Header) *Check {
+	page := httpClient.NewPage(testProduct.URL, status, header, readFixture(tb, name))
+	return &Check{Product: testProduct, Page: page, Outcome: CheckOutcome{Outcome: OutcomeError}}
+}
+
+func TestDetectCaptcha(t *testing.T) {
+	tests := []struct {
+		fixture string
+		captcha bool
+	}{
+		{"amazon_captcha.html", true},
+		{"amazon_in_stock.html", false},
+		{"amazon_out_of_stock.html", false},
+	}
+	for _, tt := range tests {
+		check := fixtureCheck(t, tt.fixture, 200, nil)
+		err := DetectCaptcha(check)
+		if got := errors.Is(err, ErrCaptchaDetected); got != tt.captcha {
+			t.Errorf("%s: DetectCaptcha = %v, want captcha %t", tt.fixture, err, tt.captcha)
+		}
+		if tt.captcha && check.Outcome.Outcome != OutcomeCaptcha {
+			t.Errorf("%s: outcome = %v, want %v", tt.fixture, check.Outcome.Outcome, OutcomeCaptcha)
+		}
+	}
+}
+
+func TestCheckResponseStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     http.Header
+		wantErr    bool
+		wantAfter  time.Duration
+		wantResult Outcome
+	}{
+		{"ok", 200, nil, false, 0, OutcomeError},
+		{"rate limited", 429, http.Header{"Retry-After": {"120"}}, true, 2 * time.Minute, OutcomeRateLimited},
+		{"forbidden", 403, nil, true, 0, OutcomeRateLimited},
+		{"maintenance", 503, nil, true, config.MaintenanceBackoff, OutcomeMaintenance},
+		{"maintenance with retry-after", 503, http.Header{"Retry-After": {"60"}}, true, time.Minute, OutcomeMaintenance},
+		{"not found", 404, nil, true, 0, OutcomeError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := fixtureCheck(t, "amazon_out_of_stock.html", tt.status, tt.header)
+			err := CheckResponseStatus(check)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckResponseStatus = %v, want error %t", err, tt.wantErr)
+			}
+			if check.Outcome.Outcome != tt.wantResult {
+				t.Errorf("outcome = %v, want %v", check.Outcome.Outcome, tt.wantResult)
+			}
+			var retryAfter *utils.RetryAfterError
+			if errors.As(err, &retryAfter) {
+				if retryAfter.After != tt.wantAfter {
+					t.Errorf("retry after = %v, want %v", retryAfter.After, tt.wantAfter)
+				}
+			} else if tt.wantAfter != 0 {
+				t.Errorf("error %v does not carry a retry delay, want %v", err, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestParseAvailability(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		inStock   bool
+		indicator string
+		price     float64
+	}{
+		{"amazon_in_stock.html", true, "#add-to-cart-button", 1999.99},
+		{"amazon_out_of_stock.html", false, "Currently unavailable", 0},
+	}
+	for _, tt := range tests {
+		check := fixtureCheck(t, tt.fixture, 200, nil)
+		if err := ParseAvailability(check); err != nil {
+			t.Fatalf("%s: ParseAvailability: %v", tt.fixture, err)
+		}
+		got := check.Result
+		if got.InStock != tt.inStock || got.Indicator != tt.indicator || got.Price != tt.price {
+			t.Errorf("%s: got in stock %t by %q at %v, want %t by %q at %v",
+				tt.fixture, got.InStock, got.Indicator, got.Price, tt.inStock, tt.indicator, tt.price)
+		}
+		if got.Outcome != OutcomeSuccess || got.Currency != "USD" || got.ProductID != testProduct.ID {
+			t.Errorf("%s: result = %+v", tt.fixture, got)
+		}
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	check := fixtureCheck(t, "amazon_captcha.html", 200, nil)
+	if err := RunPipeline(check, Pipeline); !errors.Is(err, ErrCaptchaDetected) {
+		t.Fatalf("RunPipeline on a block page = %v, want %v", err, ErrCaptchaDetected)
+	}
+
+	check = fixtureCheck(t, "amazon_in_stock.html", 200, nil)
+	if err := RunPipeline(check, Pipeline); err != nil {
+		t.Fatalf("RunPipeline: %v", err)
+	}
+	if !check.Result.InStock {
+		t.Fatalf("RunPipeline result = %+v, want in stock", check.Result)
+	}
+}
+
+func TestDebugSaveHTMLIsPrivate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	page := httpClient.NewPage(testProduct.URL, http.StatusOK, http.Header{}, []byte("<html></html>"))
+	if err := DebugSaveHTML(testProduct.ID, page); err != nil {
+		t.Fatalf("DebugSaveHTML: %v", err)
+	}
+	info, err := os.Stat(SnapshotName(testProduct.ID, page.FetchedAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("snapshot mode = %v, want -rw-------", mode)
+	}
+}
+
+func BenchmarkDetectCaptcha(b *testing.B) {
+	body := readFixture(b, "amazon_in_stock.html")
+	for i := 0; i < b.N; i++ {
+		page := httpClient.NewPage(testProduct.URL, 200, nil, body)
+		if err := DetectCaptcha(&Check{Product: testProduct, Page: page}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseAvailability(b *testing.B) {
+	for _, fixture := range []string{"amazon_in_stock.html", "amazon_out_of_stock.html"} {
+		body := readFixture(b, fixture)
+		b.Run(fixture, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				page := httpClient.NewPage(testProduct.URL, 200, nil, body)
+				if err := ParseAvailability(&Check{Product: testProduct, Page: page}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package stock
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+// Clock abstracts the current time so polling logic can run on a fake clock
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Outcome classifies how a stock check ended
+type Outcome int
+
+const (
+	OutcomeSuccess     Outcome = iota // Page fetched and parsed
+	OutcomeRateLimited                // HTTP 429/403
+	OutcomeCaptcha                    // Block page served
+	OutcomeMaintenance                // HTTP 503
+	OutcomeError                      // Network or other failure
+	OutcomeSkipped                    // Not checked while cooling down (never observed)
+	OutcomeCancelled                  // Aborted by shutdown (never observed)
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeRateLimited:
+		return "rate-limited"
+	case OutcomeCaptcha:
+		return "captcha"
+	case OutcomeMaintenance:
+		return "maintenance"
+	case OutcomeSkipped:
+		return "skipped"
+	case OutcomeCancelled:
+		return "cancelled"
+	default:
+		return "error"
+	}
+}
+
+// CheckOutcome is reported to the polling controller after every check
+type CheckOutcome struct {
+	Host    string                 // Host that was checked
+	Outcome Outcome                // How the check ended
+	Hints   httpClient.ServerHints // Scheduling hints sent by the server
+}
+
+// PollingController decides how long to wait between checks of a host.
+// Controllers can be chained: an outer controller picks the base interval
+// and passes it on to an inner one.
+type PollingController interface {
+	// Observe feeds the outcome of a finished check to the controller
+	Observe(outcome CheckOutcome)
+	// NextInterval returns the delay before the next check of host, given
+	// the interval the caller would use if nothing else applied
+	NextInterval(host string, base time.Duration) time.Duration
+}
+
+// AIMDController adapts the interval per host: its backoff factor shrinks
+// additively after clean checks and grows multiplicatively when the site
+// pushes back. Server hints (Retry-After, maintenance, cache freshness) set
+// a floor on the next check time.
+type AIMDController struct {
+	Max             time.Duration // Interval never goes above this
+	RateLimitMax    time.Duration // Cap for growth caused by rate limiting
+	Step            float64       // Additive decrease of the factor after a clean check
+	RateLimitFactor float64       // Growth after a rate-limited check without Retry-After
+	CaptchaFactor   float64       // Growth after a CAPTCHA
+	Clock           Clock
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	factor    float64       // Multiplier applied to the base interval (>= 1)
+	cap       time.Duration // Limit for the scaled interval
+	notBefore time.Time     // Earliest next check requested by the server
+}
+
+// NewAIMDController creates a controller with the application defaults
+func NewAIMDController(clock Clock) *AIMDController {
+	return &AIMDController{
+		Max:             15 * time.Minute,
+		RateLimitMax:    5 * time.Minute,
+		Step:            0.2,
+		RateLimitFactor: 2,
+		CaptchaFactor:   3,
+		Clock:           clock,
+		hosts:           make(map[string]*hostState),
+	}
+}
+
+// Observe implements PollingController
+func (c *AIMDController) Observe(outcome CheckOutcome) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(outcome.Host)
+	now := c.Clock.Now()
+
+	switch outcome.Outcome {
+	case OutcomeSuccess:
+		state.factor -= c.Step
+	case OutcomeRateLimited:
+		// An explicit Retry-After is honored below instead of guessing
+		if outcome.Hints.RetryAfter == 0 {
+			state.factor *= c.RateLimitFactor
+			state.cap = c.RateLimitMax
+		}
+	case OutcomeCaptcha:
+		state.factor *= c.CaptchaFactor
+		state.cap = c.Max
+	}
+	if state.factor <= 1 {
+		state.factor = 1
+		state.cap = c.Max
+	}
+
+	if delay := hintDelay(outcome.Hints); delay > 0 {
+		if until := now.Add(delay); until.After(state.notBefore) {
+			state.notBefore = until
+		}
+	}
+}
+
+// NextInterval implements PollingController
+func (c *AIMDController) NextInterval(host string, base time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state(host)
+	interval := time.Duration(float64(base) * state.factor)
+	if state.factor > 1 && interval > state.cap {
+		interval = state.cap
+	}
+	if interval < base {
+		interval = base
+	}
+
+	if wait := state.notBefore.Sub(c.Clock.Now()); wait > interval {
+		return wait
+	}
+	return interval
+}
+
+func (c *AIMDController) state(host string) *hostState {
+	state, ok := c.hosts[host]
+	if !ok {
+		state = &hostState{factor: 1, cap: c.Max}
+		c.hosts[host] = state
+	}
+	return state
+}
+
+// hintDelay converts server hints into the minimum delay before the next check
+func hintDelay(hints httpClient.ServerHints) time.Duration {
+	delay := hints.RetryAfter
+	if hints.Maintenance && delay == 0 {
+		delay = config.MaintenanceBackoff
+	}
+
+	// The page won't change before it expires, polling sooner is wasted
+	maxAge := hints.MaxAge
+	if maxAge > config.MaxCacheHintDelay {
+		maxAge = config.MaxCacheHintDelay
+	}
+	if maxAge > delay {
+		delay = maxAge
+	}
+
+	if delay > config.MaxServerHintDelay {
+		delay = config.MaxServerHintDelay
+	}
+	return delay
+}
+
+// ScheduleController picks the base interval from a user-defined schedule
+// evaluated in the product's time zone, then lets the inner controller
+// adapt it. Launch events take precedence over recurring windows, and
+// blackout windows postpone the next check until the window ends.
+type ScheduleController struct {
+	Inner    PollingController
+	Schedule config.Schedule
+	Location *time.Location
+	Clock    Clock
+}
+
+// NewScheduleController wraps inner with the given schedule
+func NewScheduleController(inner PollingController, schedule config.Schedule, loc *time.Location, clock Clock) *ScheduleController {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &ScheduleController{
+		Inner:    inner,
+		Schedule: schedule,
+		Location: loc,
+		Clock:    clock,
+	}
+}
+
+// Observe implements PollingController
+func (s *ScheduleController) Observe(outcome CheckOutcome) {
+	s.Inner.Observe(outcome)
+}
+
+// NextInterval implements PollingController
+func (s *ScheduleController) NextInterval(host string, base time.Duration) time.Duration {
+	now := s.Clock.Now().In(s.Location)
+
+	// One-off launch events win over everything else
+	untilLaunch := time.Duration(-1)
+	for _, event := range s.Schedule.LaunchAt {
+		at, err := event.Time(s.Location)
+		if err != nil {
+			continue
+		}
+		start := at.Add(-event.Before.Duration)
+		if !now.Before(start) && now.Before(at.Add(event.After.Duration)) {
+			return s.Inner.NextInterval(host, event.Interval.Duration)
+		}
+		if now.Before(start) && (untilLaunch < 0 || start.Sub(now) < untilLaunch) {
+			untilLaunch = start.Sub(now)
+		}
+	}
+
+	var jitter time.Duration
+	if window, ok := s.activeWindow(now); ok {
+		if window.Mode == config.WindowBlackout {
+			base = s.windowEnd(window, now).Sub(now)
+		} else if window.Interval.Duration > 0 {
+			base = window.Interval.Duration
+		}
+		jitter = window.Jitter.Duration
+	}
+
+	// Make sure an upcoming launch isn't slept through
+	if untilLaunch >= 0 && untilLaunch < base {
+		base = untilLaunch
+		jitter = 0
+	}
+
+	interval := s.Inner.NextInterval(host, base)
+	if jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	return interval
+}
+
+// ActiveWindow returns the name of the schedule window in effect, if any
+func (s *ScheduleController) ActiveWindow() (string, bool) {
+	now := s.Clock.Now().In(s.Location)
+	for _, event := range s.Schedule.LaunchAt {
+		at, err := event.Time(s.Location)
+		if err != nil {
+			continue
+		}
+		if !now.Before(at.Add(-event.Before.Duration)) && now.Before(at.Add(event.After.Duration)) {
+			return "launch " + event.Name, true
+		}
+	}
+	window, ok := s.activeWindow(now)
+	if !ok {
+		return "", false
+	}
+	return window.Name, true
+}
+
+func (s *ScheduleController) activeWindow(now time.Time) (config.ScheduleWindow, bool) {
+	minute := config.ClockTime(now.Hour()*60 + now.Minute())
+	for _, window := range s.Schedule.Windows {
+		day := now.Weekday()
+		if window.Start > window.End && minute < window.End {
+			// In the part of a window that started yesterday
+			day = (day + 6) % 7
+		}
+		if window.Days.Contains(day) && windowContains(window, minute) {
+			return window, true
+		}
+	}
+	return config.ScheduleWindow{}, false
+}
+
+// windowEnd returns the time the window containing now ends
+func (s *ScheduleController) windowEnd(window config.ScheduleWindow, now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := midnight.Add(time.Duration(window.End) * time.Minute)
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func windowContains(window config.ScheduleWindow, minute config.ClockTime) bool {
+	if window.Start <= window.End {
+		return minute >= window.Start && minute < window.End
+	}
+	// Window wraps around midnight
+	return minute >= window.Start || minute < window.End
+}
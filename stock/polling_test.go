@@ -0,0 +1,193 @@
+package stock
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+const testBase = 30 * time.Second
+
+func TestAIMDControllerBacksOffAndRecovers(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)}
+	c := NewAIMDController(clock)
+	const host = "www.amazon.com"
+
+	steps := []struct {
+		outcome Outcome
+		want    time.Duration
+	}{
+		{OutcomeSuccess, testBase},
+		{OutcomeCaptcha, 90 * time.Second},      // Factor 3
+		{OutcomeRateLimited, 3 * time.Minute},   // Factor 6
+		{OutcomeSuccess, 174 * time.Second},     // Factor 5.8
+		{OutcomeError, 174 * time.Second},       // Errors don't move the factor
+		{OutcomeMaintenance, 174 * time.Second}, // Maintenance only sets a floor through hints
+	}
+	for i, step := range steps {
+		c.Observe(CheckOutcome{Host: host, Outcome: step.outcome})
+		if got := c.NextInterval(host, testBase); got != step.want {
+			t.Fatalf("step %d (%v): NextInterval = %v, want %v", i, step.outcome, got, step.want)
+		}
+	}
+
+	// Clean checks shrink the factor additively back to the base interval
+	for i := 0; i < 40; i++ {
+		c.Observe(CheckOutcome{Host: host, Outcome: OutcomeSuccess})
+	}
+	if got := c.NextInterval(host, testBase); got != testBase {
+		t.Fatalf("after recovery NextInterval = %v, want %v", got, testBase)
+	}
+}
+
+func TestAIMDControllerCapsGrowth(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)}
+	c := NewAIMDController(clock)
+	for i := 0; i < 10; i++ {
+		c.Observe(CheckOutcome{Host: "a", Outcome: OutcomeCaptcha})
+	}
+	if got := c.NextInterval("a", testBase); got != c.Max {
+		t.Fatalf("after CAPTCHAs NextInterval = %v, want Max %v", got, c.Max)
+	}
+	for i := 0; i < 10; i++ {
+		c.Observe(CheckOutcome{Host: "b", Outcome: OutcomeRateLimited})
+	}
+	if got := c.NextInterval("b", testBase); got != c.RateLimitMax {
+		t.Fatalf("after rate limiting NextInterval = %v, want RateLimitMax %v", got, c.RateLimitMax)
+	}
+	if got := c.NextInterval("c", testBase); got != testBase {
+		t.Fatalf("untouched host NextInterval = %v, want %v", got, testBase)
+	}
+}
+
+func TestAIMDControllerHonorsHints(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome CheckOutcome
+		want    time.Duration
+	}{
+		{"retry-after", CheckOutcome{Outcome: OutcomeRateLimited, Hints: httpClient.ServerHints{RetryAfter: 10 * time.Minute}}, 10 * time.Minute},
+		{"maintenance", CheckOutcome{Outcome: OutcomeMaintenance, Hints: httpClient.ServerHints{Maintenance: true}}, config.MaintenanceBackoff},
+		{"max-age", CheckOutcome{Outcome: OutcomeSuccess, Hints: httpClient.ServerHints{MaxAge: 90 * time.Second}}, 90 * time.Second},
+		{"max-age capped", CheckOutcome{Outcome: OutcomeSuccess, Hints: httpClient.ServerHints{MaxAge: time.Hour}}, config.MaxCacheHintDelay},
+		{"retry-after capped", CheckOutcome{Outcome: OutcomeRateLimited, Hints: httpClient.ServerHints{RetryAfter: 2 * time.Hour}}, config.MaxServerHintDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)}
+			c := NewAIMDController(clock)
+			tt.outcome.Host = "h"
+			c.Observe(tt.outcome)
+			if got := c.NextInterval("h", testBase); got != tt.want {
+				t.Fatalf("NextInterval = %v, want %v", got, tt.want)
+			}
+
+			// The floor is a point in time, so it shrinks as the clock moves
+			clock.Advance(tt.want / 2)
+			if got := c.NextInterval("h", testBase); got != max(tt.want-tt.want/2, testBase) {
+				t.Fatalf("halfway NextInterval = %v, want %v", got, max(tt.want-tt.want/2, testBase))
+			}
+			clock.Advance(tt.want)
+			if got := c.NextInterval("h", testBase); got != testBase {
+				t.Fatalf("after the floor NextInterval = %v, want %v", got, testBase)
+			}
+		})
+	}
+}
+
+func TestScheduleController(t *testing.T) {
+	loc := time.FixedZone("test", -8*3600)
+	schedule := config.Schedule{
+		Windows: []config.ScheduleWindow{
+			{Name: "night", Start: 1 * 60, End: 6 * 60, Mode: config.WindowNormal, Interval: config.Duration{Duration: time.Minute}},
+			{Name: "maintenance", Start: 22 * 60, End: 23 * 60, Mode: config.WindowBlackout},
+			{Name: "friday late", Days: config.Weekdays{time.Friday}, Start: 23*60 + 30, End: 30, Mode: config.WindowBurst, Interval: config.Duration{Duration: 2 * time.Minute}},
+		},
+		LaunchAt: []config.LaunchEvent{
+			{Name: "drop", At: "2026-03-06 12:00", Before: config.Duration{Duration: 10 * time.Minute}, After: config.Duration{Duration: 10 * time.Minute}, Interval: config.Duration{Duration: 5 * time.Second}},
+		},
+	}
+
+	// 2026-03-06 is a Friday
+	tests := []struct {
+		at     time.Time
+		want   time.Duration
+		window string
+	}{
+		{time.Date(2026, 3, 5, 15, 0, 0, 0, loc), testBase, ""},
+		{time.Date(2026, 3, 5, 2, 0, 0, 0, loc), time.Minute, "night"},
+		{time.Date(2026, 3, 5, 22, 15, 0, 0, loc), 45 * time.Minute, "maintenance"},
+		{time.Date(2026, 3, 6, 11, 40, 0, 0, loc), testBase, ""},
+		{time.Date(2026, 3, 6, 11, 49, 50, 0, loc), 10 * time.Second, ""}, // Don't sleep through the launch
+		{time.Date(2026, 3, 6, 11, 55, 0, 0, loc), 5 * time.Second, "launch drop"},
+		{time.Date(2026, 3, 6, 12, 9, 0, 0, loc), 5 * time.Second, "launch drop"},
+		{time.Date(2026, 3, 6, 12, 10, 0, 0, loc), testBase, ""},
+		{time.Date(2026, 3, 6, 23, 45, 0, 0, loc), 2 * time.Minute, "friday late"},
+		{time.Date(2026, 3, 7, 0, 15, 0, 0, loc), 2 * time.Minute, "friday late"}, // Started on Friday
+		{time.Date(2026, 3, 8, 0, 15, 0, 0, loc), testBase, ""},                   // Saturday's night isn't in the window
+	}
+	for _, tt := range tests {
+		clock := &fakeClock{now: tt.at}
+		s := NewScheduleController(NewAIMDController(clock), schedule, loc, clock)
+		if got := s.NextInterval("h", testBase); got != tt.want {
+			t.Errorf("%v: NextInterval = %v, want %v", tt.at, got, tt.want)
+		}
+		window, ok := s.ActiveWindow()
+		if window != tt.window || ok != (tt.window != "") {
+			t.Errorf("%v: ActiveWindow = %q, %t, want %q", tt.at, window, ok, tt.window)
+		}
+	}
+}
+
+func TestScheduleControllerPassesOutcomesOn(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)}
+	inner := NewAIMDController(clock)
+	s := NewScheduleController(inner, config.Schedule{}, time.UTC, clock)
+	s.Observe(CheckOutcome{Host: "h", Outcome: OutcomeCaptcha})
+	if got := s.NextInterval("h", testBase); got != 90*time.Second {
+		t.Fatalf("NextInterval = %v, want %v", got, 90*time.Second)
+	}
+}
+
+func TestScheduleForIsPerEntry(t *testing.T) {
+	newYork := config.Product{ID: "B0DT7L98J1", ZIP: "10001"}
+	seattle := config.Product{ID: "B0DT7L98J1", ZIP: "98101"}
+	if ScheduleFor(newYork) == ScheduleFor(seattle) {
+		t.Error("entries of one product for different ZIPs share a schedule controller")
+	}
+	if ScheduleFor(newYork) != ScheduleFor(newYork) {
+		t.Error("ScheduleFor returned a new controller for the same entry")
+	}
+}
+
+func TestHintDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		hints httpClient.ServerHints
+		want  time.Duration
+	}{
+		{"none", httpClient.ServerHints{}, 0},
+		{"retry-after", httpClient.ServerHints{RetryAfter: 5 * time.Minute}, 5 * time.Minute},
+		{"retry-after capped", httpClient.ServerHints{RetryAfter: 24 * time.Hour}, config.MaxServerHintDelay},
+		{"retry-after saturated", httpClient.ServerHints{RetryAfter: math.MaxInt64}, config.MaxServerHintDelay},
+		{"maintenance", httpClient.ServerHints{Maintenance: true}, config.MaintenanceBackoff},
+		{"maintenance with retry-after", httpClient.ServerHints{Maintenance: true, RetryAfter: time.Minute}, time.Minute},
+		{"max-age", httpClient.ServerHints{MaxAge: time.Minute}, time.Minute},
+		{"max-age capped", httpClient.ServerHints{MaxAge: time.Hour}, config.MaxCacheHintDelay},
+		{"longest wins", httpClient.ServerHints{RetryAfter: 30 * time.Second, MaxAge: 90 * time.Second}, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := hintDelay(tt.hints); got != tt.want {
+			t.Errorf("%s: hintDelay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
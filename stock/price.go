@@ -0,0 +1,94 @@
+package stock
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"gpu-sniper/config"
+)
+
+// priceSelectors locate the buy-box price, most specific first
+var priceSelectors = []string{
+	"#corePrice_feature_div .a-price .a-offscreen",
+	"#corePriceDisplay_desktop_feature_div .a-price .a-offscreen",
+	"#price_inside_buybox",
+	"#priceblock_ourprice",
+	"#priceblock_dealprice",
+	"#buybox .a-price .a-offscreen",
+}
+
+// ParsePrice reads a displayed price such as "$1,999.00", "1.999,00 €" or
+// "￥229,800" using the marketplace's decimal separator to resolve
+// ambiguous amounts like "1.999"
+func ParsePrice(text string, market config.Marketplace) (float64, bool) {
+	var digits strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			digits.WriteRune(r)
+		case r >= '０' && r <= '９':
+			digits.WriteRune('0' + (r - '０')) // Full-width digits
+		case r == '，' || r == '．':
+			digits.WriteRune(r - '，' + ',') // Full-width separators
+		case digits.Len() > 0 && !unicode.IsSpace(r) && r != '\'':
+			// Stop at the first letter or symbol after the amount
+			return parseAmount(digits.String(), market.Decimal)
+		}
+	}
+	return parseAmount(digits.String(), market.Decimal)
+}
+
+func parseAmount(s string, decimal byte) (float64, bool) {
+	s = strings.Trim(s, ".,")
+	if s == "" {
+		return 0, false
+	}
+
+	lastDot, lastComma := strings.LastIndexByte(s, '.'), strings.LastIndexByte(s, ',')
+	sep := -1
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: whichever comes last is the decimal separator
+		sep = max(lastDot, lastComma)
+	case lastDot >= 0 || lastComma >= 0:
+		idx := max(lastDot, lastComma)
+		single := strings.Count(s, string(s[idx])) == 1
+		// A lone separator followed by three digits groups thousands unless
+		// it is the marketplace's decimal separator
+		if single && (len(s)-idx-1 != 3 || s[idx] == decimal) {
+			sep = idx
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == sep:
+			b.WriteByte('.')
+		case s[i] >= '0' && s[i] <= '9':
+			b.WriteByte(s[i])
+		}
+	}
+	value, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// findPrice returns the first buy-box price on the page
+func findPrice(doc *goquery.Document, market config.Marketplace) (float64, bool) {
+	for _, selector := range priceSelectors {
+		text := strings.TrimSpace(doc.Find(selector).First().Text())
+		if text == "" {
+			continue
+		}
+		if price, ok := ParsePrice(text, market); ok {
+			return price, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package stock
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gpu-sniper/config"
+)
+
+func TestParsePrice(t *testing.T) {
+	us, _ := config.MarketplaceFor("www.amazon.com")
+	de, _ := config.MarketplaceFor("www.amazon.de")
+	fr, _ := config.MarketplaceFor("www.amazon.fr")
+	jp, _ := config.MarketplaceFor("www.amazon.co.jp")
+
+	tests := []struct {
+		text   string
+		market config.Marketplace
+		want   float64
+		ok     bool
+	}{
+		{"$1,999.99", us, 1999.99, true},
+		{"$1,999", us, 1999, true},
+		{"$19.99", us, 19.99, true},
+		{"1.999,00 €", de, 1999, true},
+		{"1.999 €", de, 1999, true}, // A lone dot before three digits groups thousands in Germany
+		{"1,999 €", de, 1.999, true},
+		{"1.999", us, 1.999, true},
+		{"1\u00a0999,00\u00a0€", fr, 1999, true}, // No-break space
+		{"1\u202f999,00\u00a0€", fr, 1999, true}, // Narrow no-break space
+		{"2 199,99 €", fr, 2199.99, true},
+		{"￥229,800", jp, 229800, true},
+		{"￥２２９，８００", jp, 229800, true}, // Full-width digits
+		{"$1,999.99 - $2,499.99", us, 1999.99, true},
+		{"Currently unavailable", us, 0, false},
+		{"", us, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePrice(tt.text, tt.market)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParsePrice(%q, %s) = %v, %t, want %v, %t", tt.text, tt.market.Domain, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s       string
+		decimal byte
+		want    float64
+		ok      bool
+	}{
+		{"1.999,00", ',', 1999, true},
+		{"1,999.00", '.', 1999, true},
+		{"1.999", ',', 1999, true},
+		{"1.999", '.', 1.999, true},
+		{"1,999", '.', 1999, true},
+		{"1,999", ',', 1.999, true},
+		{"1.234.567", ',', 1234567, true},
+		{"1,234,567", '.', 1234567, true},
+		{"12,5", ',', 12.5, true},
+		{"12.50.", '.', 12.5, true}, // Trailing separator from a sentence
+		{".,", '.', 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAmount(tt.s, tt.decimal)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseAmount(%q, %q) = %v, %t, want %v, %t", tt.s, tt.decimal, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseStockStatusWithoutRequest(t *testing.T) {
+	body := string(readFixture(t, "amazon_in_stock.html"))
+	resp := &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	inStock, err := ParseStockStatus(resp)
+	if err != nil || !inStock {
+		t.Fatalf("ParseStockStatus = %t, %v, want in stock", inStock, err)
+	}
+
+	u, _ := url.Parse("https://www.amazon.de/dp/B0DT7L98J1")
+	resp = &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: &http.Request{URL: u}}
+	if _, err := ParseStockStatus(resp); err != nil {
+		t.Fatalf("ParseStockStatus: %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package stock
 
 import (
+    "context"
     "sync"
     "time"
+
+    "gpu-sniper/utils"
 )
 
 // RateLimiter implements a simple token bucket rate limiter
@@ -22,8 +25,8 @@ func NewRateLimiter(tokensPerMinute int) *RateLimiter {
     }
 }
 
-// Wait blocks until a token is available
-func (rl *RateLimiter) Wait() {
+// Wait blocks until a token is available or ctx is cancelled
+func (rl *RateLimiter) Wait(ctx context.Context) error {
     rl.mu.Lock()
     defer rl.mu.Unlock()
     
@@ -45,11 +48,15 @@ func (rl *RateLimiter) Wait() {
     if rl.tokens <= 0 {
         rl.mu.Unlock() // Release lock while waiting
         sleepTime := time.Second * 60 / time.Duration(rl.tokensPerMinute)
-        time.Sleep(sleepTime)
+        err := utils.Sleep(ctx, sleepTime)
         rl.mu.Lock()
+        if err != nil {
+            return err
+        }
         rl.tokens = 1 // Guaranteed to have 1 token after sleeping
     }
     
     // Consume token
     rl.tokens--
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,87 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+// snapshotTimeLayout is the fetch time in snapshot file names, local time
+const snapshotTimeLayout = "20060102_150405"
+
+// unsafeNameChars are replaced in product IDs used in file names
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// snapshotNamePattern matches names written by SnapshotName
+var snapshotNamePattern = regexp.MustCompile(`^debug_([A-Za-z0-9-]+)_(\d{8}_\d{6})\.html$`)
+
+// SnapshotName is the file name a page fetched for the product at the given
+// time is saved under, e.g. debug_B0DT7L98J1_20250130_090000.html
+func SnapshotName(productID string, fetchedAt time.Time) string {
+	return fmt.Sprintf("debug_%s_%s.html", snapshotID(productID), fetchedAt.Local().Format(snapshotTimeLayout))
+}
+
+// ParseSnapshotName returns the product ID as written in a snapshot name and
+// the fetch time, to the second
+func ParseSnapshotName(name string) (string, time.Time, bool) {
+	match := snapshotNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", time.Time{}, false
+	}
+	at, err := time.ParseInLocation(snapshotTimeLayout, match[2], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return match[1], at, true
+}
+
+// SnapshotKey identifies the snapshot of a check of the product at the given
+// time among the names ParseSnapshotName accepts
+func SnapshotKey(productID string, at time.Time) string {
+	return snapshotID(productID) + "_" + at.Local().Format(snapshotTimeLayout)
+}
+
+func snapshotID(productID string) string {
+	return strings.Trim(unsafeNameChars.ReplaceAllString(productID, "-"), "-")
+}
+
+// ErrNoReplay is returned for products whose source doesn't save snapshots
+var ErrNoReplay = errors.New("source does not save page snapshots")
+
+// ReplaySnapshot decides availability from a saved page the way a live
+// check of the product would, skipping the stages that depend on the
+// request such as the status and location checks
+func ReplaySnapshot(product config.Product, page *httpClient.Page) (Result, error) {
+	var stages []Stage
+	switch source := SourceFor(product).(type) {
+	case pageSource:
+		stages = Pipeline
+	case neweggSource:
+		stages = NeweggPipeline
+	case *customSource:
+		stages = CustomPipeline
+	default:
+		return Result{}, fmt.Errorf("%s: %w", source.Name(), ErrNoReplay)
+	}
+
+	var replay []Stage
+	for _, stage := range stages {
+		switch stage.Name {
+		case "captcha", "availability":
+			replay = append(replay, stage)
+		}
+	}
+	check := &Check{Product: product, Page: page}
+	if err := RunPipeline(check, replay); err != nil {
+		if check.Outcome.Outcome == OutcomeCaptcha {
+			return Result{ProductID: product.ID, CheckedAt: page.FetchedAt, Outcome: OutcomeCaptcha, Err: err}, nil
+		}
+		return Result{}, err
+	}
+	return check.Result, nil
+}
@@ -0,0 +1,221 @@
+package stock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+)
+
+// Retailer adapts stock checks to one retailer's site
+type Retailer interface {
+	Name() string
+
+	// SetLocation makes the session see the offers available for delivery
+	// to zip on the site serving product
+	SetLocation(ctx context.Context, session *httpClient.Session, product config.Product, zip string) error
+}
+
+// ErrLocationUnsupported is returned by retailers without delivery locations
+var ErrLocationUnsupported = errors.New("delivery location is not supported for this retailer")
+
+// RetailerFor returns the adapter for the site a product is sold on
+func RetailerFor(product config.Product) Retailer {
+	switch product.Retailer {
+	case config.RetailerAmazon:
+		return amazon{}
+	}
+	if strings.Contains(strings.ToLower(hostOf(product.URL)), "amazon.") {
+		return amazon{}
+	}
+	return genericRetailer{}
+}
+
+type genericRetailer struct{}
+
+func (genericRetailer) Name() string { return "generic" }
+
+func (genericRetailer) SetLocation(context.Context, *httpClient.Session, config.Product, string) error {
+	return ErrLocationUnsupported
+}
+
+// Delivery locations currently established in the session, by host
+var (
+	locationsMu sync.Mutex
+	locations   = make(map[string]string)
+)
+
+// establishLocation makes sure the session delivers to the product's ZIP
+// code before it is checked. Products on one host that share a ZIP code
+// only set it once. It returns the location the check runs with.
+func establishLocation(ctx context.Context, product config.Product) string {
+	if product.ZIP == "" {
+		return ""
+	}
+	host := hostOf(product.URL)
+
+	locationsMu.Lock()
+	current := locations[host]
+	locationsMu.Unlock()
+	if current == product.ZIP {
+		return product.ZIP
+	}
+
+	retailer := RetailerFor(product)
+	ui.LogInfo("Setting delivery location to %s on %s", product.ZIP, host)
+	if err := retailer.SetLocation(ctx, Session, product, product.ZIP); err != nil {
+		ui.LogWarning("Could not set delivery location %s for %s: %v", product.ZIP, product.DisplayName(), err)
+		forgetLocation(host)
+		return ""
+	}
+
+	locationsMu.Lock()
+	locations[host] = product.ZIP
+	locationsMu.Unlock()
+	return product.ZIP
+}
+
+// forgetLocation makes the next check on host set its location again
+func forgetLocation(host string) {
+	locationsMu.Lock()
+	delete(locations, host)
+	locationsMu.Unlock()
+}
+
+// amazon sets the delivery location through the "Deliver to" popover
+type amazon struct{}
+
+func (amazon) Name() string { return "amazon" }
+
+var (
+	amazonModalToken = regexp.MustCompile(`"anti-csrftoken-a2z"\s*:\s*"([^"]+)"`)
+	amazonGlowToken  = regexp.MustCompile(`CSRF_TOKEN\s*:\s*"([^"]+)"`)
+)
+
+func (amazon) SetLocation(ctx context.Context, session *httpClient.Session, product config.Product, zip string) error {
+	base := httpClient.ExtractBaseURL(product.URL)
+
+	// The homepage carries the token that opens the location popover
+	homepage, err := amazonGet(ctx, session, base+"/", "")
+	if err != nil {
+		return err
+	}
+	match := amazonModalToken.FindStringSubmatch(html.UnescapeString(homepage))
+	if match == nil {
+		return fmt.Errorf("location popover token not found on %s", base)
+	}
+
+	// The popover carries the token for changing the address
+	popover, err := amazonGet(ctx, session, base+"/portal-migration/hz/glow/get-rendered-address-selections?deviceType=desktop&pageType=Gateway&storeContext=NoStoreName&actionSource=desktop-modal", match[1])
+	if err != nil {
+		return err
+	}
+	match = amazonGlowToken.FindStringSubmatch(popover)
+	if match == nil {
+		return fmt.Errorf("address change token not found")
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"locationType": "LOCATION_INPUT",
+		"zipCode":      zip,
+		"storeContext": "generic",
+		"deviceType":   "web",
+		"pageType":     "Gateway",
+		"actionSource": "glow",
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/portal-migration/hz/glow/address-change?actionSource=glow", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", session.UserAgent)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/html,*/*")
+	req.Header.Set("Referer", base+"/")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("anti-csrftoken-a2z", match[1])
+	resp, err := session.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("address change failed: %w", err)
+	}
+	data, err := httpClient.ReadDecodedBody(resp)
+	if err != nil {
+		return fmt.Errorf("address change failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("address change failed: HTTP %d", resp.StatusCode)
+	}
+
+	var reply struct {
+		IsValidAddress int    `json:"isValidAddress"`
+		Address        string `json:"address"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("unexpected address change response: %w", err)
+	}
+	if reply.IsValidAddress != 1 {
+		return fmt.Errorf("%s rejected ZIP code %s", base, zip)
+	}
+	ui.LogSuccess("Delivery location set to %s", zip)
+	return nil
+}
+
+// amazonGet fetches an Amazon page within the session, sending token as
+// the anti-CSRF header when set
+func amazonGet(ctx context.Context, session *httpClient.Session, pageURL, token string) (string, error) {
+	req, err := session.CreateRequest(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("anti-csrftoken-a2z", token)
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	}
+	resp, err := session.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+	data, err := httpClient.ReadDecodedBody(resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", pageURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d fetching %s", resp.StatusCode, pageURL)
+	}
+	return string(data), nil
+}
+
+// amazonDeliveryLine matches the "Deliver to" line in Amazon's page header
+var amazonDeliveryLine = "#glow-ingress-line2"
+
+// VerifyLocation checks that the page was rendered for the product's
+// delivery location. A mismatch makes the next check set it again. Streamed
+// pages are searched up to where the streaming parser stopped reading.
+func VerifyLocation(check *Check) error {
+	if check.Product.ZIP == "" {
+		return nil
+	}
+	doc, err := check.Page.Document()
+	if err != nil {
+		return nil // Availability parsing reports unreadable pages
+	}
+	shown := strings.TrimSpace(doc.Find(amazonDeliveryLine).First().Text())
+	if shown == "" {
+		return nil
+	}
+	if !strings.Contains(strings.ReplaceAll(shown, " ", ""), strings.ReplaceAll(check.Product.ZIP, " ", "")) {
+		ui.LogWarning("Page shows delivery to %q instead of %s; results may not match the location", shown, check.Product.ZIP)
+		check.Result.Location = ""
+		forgetLocation(hostOf(check.Product.URL))
+	}
+	return nil
+}
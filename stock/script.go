@@ -0,0 +1,335 @@
+package stock
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+	"gpu-sniper/ui"
+)
+
+// scriptFileOptions are the Starlark dialect product scripts are written in
+var scriptFileOptions = &syntax.FileOptions{Set: true, While: true}
+
+// scriptResultKeys are the keys a script's result dict may contain
+var scriptResultKeys = map[string]string{
+	"in_stock":  "bool",
+	"price":     "float",
+	"currency":  "string",
+	"seller":    "string",
+	"title":     "string",
+	"indicator": "string",
+	"cart_url":  "string",
+	"condition": "string",
+}
+
+// productScript is a loaded script; its check function is frozen so runs
+// can't leave state behind for the next check
+type productScript struct {
+	path  string
+	check starlark.Callable
+}
+
+// productScripts are the loaded scripts, by path
+var productScripts = map[string]*productScript{}
+
+// LoadScripts loads the script of every product that has one, so syntax
+// errors and a missing check function are reported at startup
+func LoadScripts() error {
+	loaded := make(map[string]*productScript)
+	for _, product := range config.Products {
+		if product.Script == "" || loaded[product.Script] != nil {
+			continue
+		}
+		script, err := loadScript(product.Script)
+		if err != nil {
+			return fmt.Errorf("product %s: %w", product.ID, err)
+		}
+		loaded[product.Script] = script
+	}
+	productScripts = loaded
+	return nil
+}
+
+func loadScript(path string) (*productScript, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	thread := newScriptThread(path)
+	stop := limitScript(thread)
+	globals, err := starlark.ExecFileOptions(scriptFileOptions, thread, path, src, scriptBuiltins)
+	stop()
+	if err != nil {
+		return nil, scriptError(path, err)
+	}
+	check, ok := globals["check"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("script %s does not define check(response)", path)
+	}
+	return &productScript{path: path, check: check}, nil
+}
+
+// newScriptThread returns a thread without load() whose print goes to the log
+func newScriptThread(path string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  path,
+		Print: func(_ *starlark.Thread, msg string) { ui.LogInfo("%s: %s", path, msg) },
+	}
+	thread.SetMaxExecutionSteps(config.ScriptMaxSteps)
+	return thread
+}
+
+// limitScript cancels the thread once config.ScriptTimeout has passed; call
+// the returned function when the script finished
+func limitScript(thread *starlark.Thread) func() {
+	timer := time.AfterFunc(config.ScriptTimeout, func() {
+		thread.Cancel(fmt.Sprintf("exceeded the %v time limit", config.ScriptTimeout))
+	})
+	return func() { timer.Stop() }
+}
+
+func scriptError(path string, err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("script %s: %s", path, evalErr.Backtrace())
+	}
+	return fmt.Errorf("script %s: %w", path, err)
+}
+
+// RunScript is the availability stage of products with a script: it calls
+// the script's check function with the response and turns the dict it
+// returns into the result
+func RunScript(check *Check) error {
+	script, ok := productScripts[check.Product.Script]
+	if !ok {
+		return Permanent(fmt.Errorf("script %s was not loaded", check.Product.Script))
+	}
+	result, err := script.run(check.Page)
+	if err != nil {
+		return err
+	}
+	ui.LogInfo("Script %s decided: %s", script.path, result.Indicator)
+
+	result.ProductID = check.Product.ID
+	result.CheckedAt = check.Page.FetchedAt
+	result.Outcome = OutcomeSuccess
+	result.Location = check.Result.Location
+	if result.Currency == "" {
+		result.Currency = check.Product.Market().Currency
+	}
+	check.Result = result
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// run calls the script's check function on a response within the limits
+func (s *productScript) run(page *httpClient.Page) (Result, error) {
+	thread := newScriptThread(s.path)
+	stop := limitScript(thread)
+	value, err := starlark.Call(thread, s.check, starlark.Tuple{scriptResponse(page)}, nil)
+	stop()
+	if err != nil {
+		return Result{}, scriptError(s.path, err)
+	}
+	result, err := scriptResult(value)
+	if err != nil {
+		return Result{}, fmt.Errorf("script %s: %w", s.path, err)
+	}
+	return result, nil
+}
+
+// scriptResponse exposes a response to a script as
+// response.status, .url, .headers, .body, .query(css) and .json()
+func scriptResponse(page *httpClient.Page) starlark.Value {
+	headers := starlark.NewDict(len(page.Header))
+	names := make([]string, 0, len(page.Header))
+	for name := range page.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers.SetKey(starlark.String(strings.ToLower(name)), starlark.String(page.Header.Get(name)))
+	}
+	headers.Freeze()
+
+	body := page.Text()
+	root := func() (*goquery.Selection, error) {
+		doc, err := page.Document()
+		if err != nil {
+			return nil, err
+		}
+		return doc.Selection, nil
+	}
+	return starlarkstruct.FromStringDict(starlark.String("response"), starlark.StringDict{
+		"status":  starlark.MakeInt(page.StatusCode),
+		"url":     starlark.String(page.URL),
+		"headers": headers,
+		"body":    starlark.String(body),
+		"query":   queryBuiltin(root),
+		"json": starlark.NewBuiltin("json", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			decode := json.Module.Members["decode"]
+			return starlark.Call(thread, decode, starlark.Tuple{starlark.String(body)}, nil)
+		}),
+	})
+}
+
+// queryBuiltin returns query(css), which lists the elements below root that
+// match a CSS selector as structs with text, html(), attrs and their own query
+func queryBuiltin(root func() (*goquery.Selection, error)) *starlark.Builtin {
+	return starlark.NewBuiltin("query", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var selector string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &selector); err != nil {
+			return nil, err
+		}
+		matcher, err := cascadia.Compile(selector)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid selector %q: %w", selector, err)
+		}
+		selection, err := root()
+		if err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+
+		var elements []starlark.Value
+		selection.FindMatcher(matcher).Each(func(_ int, element *goquery.Selection) {
+			elements = append(elements, scriptElement(element))
+		})
+		return starlark.NewList(elements), nil
+	})
+}
+
+func scriptElement(element *goquery.Selection) starlark.Value {
+	attrs := starlark.NewDict(0)
+	if len(element.Nodes) > 0 {
+		for _, attr := range element.Nodes[0].Attr {
+			attrs.SetKey(starlark.String(attr.Key), starlark.String(attr.Val))
+		}
+	}
+	attrs.Freeze()
+	return starlarkstruct.FromStringDict(starlark.String("element"), starlark.StringDict{
+		"tag":  starlark.String(goquery.NodeName(element)),
+		"text": starlark.String(strings.TrimSpace(element.Text())),
+		// Rendered only when asked for: most scripts never need it
+		"html": starlark.NewBuiltin("html", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			html, err := element.Html()
+			if err != nil {
+				return nil, fmt.Errorf("html: %w", err)
+			}
+			return starlark.String(html), nil
+		}),
+		"attrs": attrs,
+		"query": queryBuiltin(func() (*goquery.Selection, error) { return element, nil }),
+	})
+}
+
+// scriptBuiltins are the globals every script sees besides the Starlark
+// universe
+var scriptBuiltins = starlark.StringDict{
+	"json":  json.Module,
+	"match": starlark.NewBuiltin("match", scriptMatch),
+}
+
+// scriptMatch implements match(pattern, text): the groups of the first
+// match as a list (the whole match first), or None
+func scriptMatch(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("match: %w", err)
+	}
+	groups := re.FindStringSubmatch(text)
+	if groups == nil {
+		return starlark.None, nil
+	}
+	values := make([]starlark.Value, len(groups))
+	for i, group := range groups {
+		values[i] = starlark.String(group)
+	}
+	return starlark.NewList(values), nil
+}
+
+// scriptResult checks the dict returned by a script and converts it
+func scriptResult(value starlark.Value) (Result, error) {
+	dict, ok := value.(*starlark.Dict)
+	if !ok {
+		return Result{}, fmt.Errorf("check must return a dict, got %s", value.Type())
+	}
+
+	var result Result
+	hasInStock := false
+	for _, item := range dict.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return Result{}, fmt.Errorf("result keys must be strings, got %s", item[0].Type())
+		}
+		want, known := scriptResultKeys[key]
+		if !known {
+			return Result{}, fmt.Errorf("unknown result key %q", key)
+		}
+		if item[1] == starlark.None {
+			continue
+		}
+
+		switch want {
+		case "bool":
+			b, ok := item[1].(starlark.Bool)
+			if !ok {
+				return Result{}, fmt.Errorf("result %q must be a bool, got %s", key, item[1].Type())
+			}
+			result.InStock, hasInStock = bool(b), true
+		case "float":
+			f, ok := starlark.AsFloat(item[1])
+			if !ok || f < 0 {
+				return Result{}, fmt.Errorf("result %q must be a non-negative number, got %s", key, item[1])
+			}
+			result.Price = f
+		case "string":
+			s, ok := starlark.AsString(item[1])
+			if !ok {
+				return Result{}, fmt.Errorf("result %q must be a string, got %s", key, item[1].Type())
+			}
+			switch key {
+			case "currency":
+				result.Currency = strings.ToUpper(s)
+			case "seller":
+				result.Seller = s
+			case "title":
+				result.Title = s
+			case "indicator":
+				result.Indicator = s
+			case "cart_url":
+				result.CartURL = s
+			case "condition":
+				result.Condition = strings.ToLower(s)
+			}
+		}
+	}
+	if !hasInStock {
+		return Result{}, fmt.Errorf("result has no in_stock")
+	}
+	if result.Indicator == "" {
+		result.Indicator = fmt.Sprintf("script in_stock=%t", result.InStock)
+	}
+	return result, nil
+}
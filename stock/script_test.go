@@ -0,0 +1,149 @@
+package stock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+const scriptPage = `<html><body><div id="buybox"><span class="price">$1,999.99</span>
+<button id="add" data-sku="NVGFT590">Add to Cart</button></div></body></html>`
+
+// loadTestScript writes src to a file and loads it
+func loadTestScript(t *testing.T, src string) (*productScript, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "check.star")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadScript(path)
+}
+
+// runTestScript loads src and runs it on scriptPage
+func runTestScript(t *testing.T, src string) (Result, error) {
+	t.Helper()
+	script, err := loadTestScript(t, src)
+	if err != nil {
+		t.Fatalf("loadScript: %v", err)
+	}
+	return script.run(httpClient.NewPage("https://shop.example/p/1", 200, nil, []byte(scriptPage)))
+}
+
+func TestScriptReadsThePage(t *testing.T) {
+	result, err := runTestScript(t, `
+def check(response):
+    button = response.query("#buybox button")[0]
+    price = match(r"\$([\d,.]+)", response.query(".price")[0].text)[1]
+    return {
+        "in_stock": button.text == "Add to Cart" and response.status == 200,
+        "price": float(price.replace(",", "")),
+        "seller": button.attrs["data-sku"],
+        "indicator": button.html(),
+        "currency": "usd",
+        "title": None,
+    }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.InStock || result.Price != 1999.99 || result.Seller != "NVGFT590" || result.Currency != "USD" || result.Indicator != "Add to Cart" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestScriptStepLimit(t *testing.T) {
+	steps, timeout := config.ScriptMaxSteps, config.ScriptTimeout
+	config.ScriptMaxSteps, config.ScriptTimeout = 10_000, time.Minute
+	defer func() { config.ScriptMaxSteps, config.ScriptTimeout = steps, timeout }()
+
+	_, err := runTestScript(t, `
+def check(response):
+    while True:
+        pass
+`)
+	if err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Fatalf("run = %v, want the step limit", err)
+	}
+
+	// The limit also covers the top level, which runs at load
+	if _, err := loadTestScript(t, "def spin():\n    while True:\n        pass\n\nspin()\n"); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Fatalf("loadScript = %v, want the step limit", err)
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	steps, timeout := config.ScriptMaxSteps, config.ScriptTimeout
+	config.ScriptMaxSteps, config.ScriptTimeout = 0, 50*time.Millisecond // 0 means no step limit
+	defer func() { config.ScriptMaxSteps, config.ScriptTimeout = steps, timeout }()
+
+	start := time.Now()
+	_, err := runTestScript(t, `
+def check(response):
+    while True:
+        pass
+`)
+	if err == nil || !strings.Contains(err.Error(), "time limit") {
+		t.Fatalf("run = %v, want the time limit", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the script ran for %v", elapsed)
+	}
+}
+
+func TestScriptSandbox(t *testing.T) {
+	tests := []struct {
+		name, src string
+		errText   string
+	}{
+		{"load", `load("other.star", "helper")` + "\ndef check(response):\n    return {}\n", "load not implemented"},
+		{"open", "def check(response):\n    return open('/etc/passwd')\n", "undefined: open"},
+		{"no check", "x = 1\n", "does not define check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := loadTestScript(t, tt.src)
+			if err == nil {
+				_, err = script.run(httpClient.NewPage("https://shop.example/p/1", 200, nil, []byte(scriptPage)))
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestScriptResultValidation(t *testing.T) {
+	tests := []struct {
+		result  string
+		errText string
+	}{
+		{`{"in_stock": False}`, ""},
+		{`{"in_stock": True, "price": 10, "condition": "New"}`, ""},
+		{`[True]`, "must return a dict, got list"},
+		{`None`, "must return a dict, got NoneType"},
+		{`{}`, "no in_stock"},
+		{`{"in_stock": None}`, "no in_stock"},
+		{`{"in_stock": "yes"}`, `"in_stock" must be a bool, got string`},
+		{`{"in_stock": 1}`, `"in_stock" must be a bool, got int`},
+		{`{"in_stock": True, "price": "10"}`, `"price" must be a non-negative number`},
+		{`{"in_stock": True, "price": -1}`, `"price" must be a non-negative number`},
+		{`{"in_stock": True, "seller": 5}`, `"seller" must be a string, got int`},
+		{`{"in_stock": True, "cart_url": ["x"]}`, `"cart_url" must be a string, got list`},
+		{`{"in_stock": True, "stock": 3}`, `unknown result key "stock"`},
+		{`{"in_stock": True, 1: "x"}`, "keys must be strings, got int"},
+	}
+	for _, tt := range tests {
+		_, err := runTestScript(t, "def check(response):\n    return "+tt.result+"\n")
+		switch {
+		case tt.errText == "" && err != nil:
+			t.Errorf("%s: %v", tt.result, err)
+		case tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)):
+			t.Errorf("%s: error = %v, want one containing %q", tt.result, err, tt.errText)
+		}
+	}
+}
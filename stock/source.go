@@ -0,0 +1,82 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+
+	"gpu-sniper/config"
+	"gpu-sniper/ui"
+)
+
+// Source decides the availability of a product. Fetch is one attempt that
+// fills check.Result; CheckStock retries failed attempts, tracks the outcome
+// and reports the result.
+type Source interface {
+	Name() string
+
+	// Prepare runs once per check before the first attempt and returns the
+	// delivery location the check runs with, if any
+	Prepare(ctx context.Context, product config.Product) string
+
+	// Fetch runs one attempt of the check
+	Fetch(ctx context.Context, check *Check) error
+}
+
+// sources maps retailers to the source that checks their products
+var sources = map[string]Source{
+	config.RetailerAmazon:  pageSource{},
+	config.RetailerBestBuy: bestBuySource{},
+	config.RetailerNewegg:  neweggSource{},
+	config.RetailerNVIDIA:  nvidiaSource{},
+}
+
+// SourceFor returns the source that checks a product, defaulting to
+// scraping its product page
+func SourceFor(product config.Product) Source {
+	if product.Source == config.SourcePAAPI {
+		return paapiSource{}
+	}
+	if source, ok := customSources[product.Source]; ok {
+		return source
+	}
+	if source, ok := sources[product.Retailer]; ok {
+		return source
+	}
+	return pageSource{}
+}
+
+// pageSource scrapes the product page and runs it through Pipeline or
+// StreamPipeline
+type pageSource struct{}
+
+func (pageSource) Name() string { return "page" }
+
+func (pageSource) Prepare(ctx context.Context, product config.Product) string {
+	Session.VisitRelatedPage(ctx, product.URL)
+	return establishLocation(ctx, product)
+}
+
+func (pageSource) Fetch(ctx context.Context, check *Check) error {
+	// Create and send HTTP request
+	req, err := Session.CreateRequest(ctx, check.Product.URL)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	ui.LogInfo("Fetching page: %s", check.Product.URL)
+	fetch, stages := Session.FetchPage, Pipeline
+	if config.Parser == config.ParserStream {
+		// Read the body only as far as the streaming parser needs
+		fetch, stages = Session.OpenPage, StreamPipeline
+	}
+	page, err := fetch(req)
+	if err != nil {
+		return err
+	}
+	defer page.Close()
+
+	// Run the fetched page through captcha detection, debug capture,
+	// status handling and parsing
+	check.Page = page
+	return RunPipeline(check, stages)
+}
@@ -0,0 +1,29 @@
+package stock
+
+import (
+	"maps"
+	"time"
+
+	"gpu-sniper/config"
+)
+
+// Status is a snapshot of the stock checker state
+type Status struct {
+	Checks    int                      // Number of checks performed
+	LastCheck time.Time                // Time of the last check
+	Intervals map[string]time.Duration // Current polling interval by product key
+	Cooldowns map[string]Cooldown      // Hosts whose checks are paused after a CAPTCHA
+}
+
+// GetStatus returns the current state of the stock checker
+func GetStatus() Status {
+	intervalsMu.Lock()
+	current := maps.Clone(intervals)
+	intervalsMu.Unlock()
+	return Status{
+		Checks:    config.CheckCount,
+		LastCheck: config.LastCheckTime,
+		Intervals: current,
+		Cooldowns: activeCooldowns(time.Now()),
+	}
+}
@@ -0,0 +1,300 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"gpu-sniper/config"
+	"gpu-sniper/ui"
+)
+
+// buyBoxIDs are the containers whose contents decide availability on their own
+var buyBoxIDs = map[string]bool{
+	"availability":   true,
+	"outOfStock":     true,
+	"buybox":         true,
+	"desktop_buybox": true,
+}
+
+// priceIDs are the containers holding the buy-box price
+var priceIDs = map[string]bool{
+	"corePrice_feature_div":                true,
+	"corePriceDisplay_desktop_feature_div": true,
+	"price_inside_buybox":                  true,
+	"priceblock_ourprice":                  true,
+	"priceblock_dealprice":                 true,
+}
+
+// voidElements never have an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "wbr": true,
+}
+
+// StreamDecision is the outcome of scanning a page with the streaming parser
+type StreamDecision struct {
+	InStock   bool    // Whether an add-to-cart marker was found
+	Captcha   bool    // Whether the page is a block page
+	Indicator string  // Marker that decided the result
+	Price     float64 // Buy-box price if it appeared before the decision, else 0
+	BytesRead int64   // How much of the body was read before deciding
+}
+
+// ParseStockStatusStream scans HTML from r with a tokenizer and stops
+// reading as soon as a buy-box marker decides availability. Labels and
+// out-of-stock phrases are those of the given marketplace.
+func ParseStockStatusStream(r io.Reader, market config.Marketplace) (StreamDecision, error) {
+	counter := &countingReader{r: r}
+	decision, err := scanAvailability(counter, market)
+	decision.BytesRead = counter.n
+	return decision, err
+}
+
+// scanAvailability mirrors parsePage without building a DOM: any enabled
+// add-to-cart element decides "in stock" immediately, out-of-stock text in
+// the buy box decides "out of stock" immediately, and out-of-stock text
+// elsewhere only decides once the whole page was scanned.
+func scanAvailability(r io.Reader, market config.Marketplace) (StreamDecision, error) {
+	tokenizer := html.NewTokenizer(r)
+
+	var (
+		buyBoxDepth    int    // Open elements inside a buy-box container
+		skipDepth      int    // Open <script>/<style> elements
+		buttonText     string // Text collected inside the current <button>
+		inButton       bool
+		pageOutOfStock string // Out-of-stock text seen outside the buy box
+		priceDepth     int    // Open elements inside a price container
+		priceText      string // Text of the current price element
+		inPrice        bool
+		price          float64
+	)
+	decide := func(decision StreamDecision) (StreamDecision, error) {
+		decision.Price = price
+		return decision, nil
+	}
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				return StreamDecision{}, fmt.Errorf("error parsing HTML: %w", err)
+			}
+			return decide(StreamDecision{Indicator: pageOutOfStock})
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			attrs := attrMap(token.Attr)
+
+			if token.Data == "script" || token.Data == "style" {
+				if token.Type == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if token.Data == "form" && strings.Contains(strings.ToLower(attrs["action"]), "validatecaptcha") {
+				return decide(StreamDecision{Captcha: true, Indicator: "form[action*=validateCaptcha]"})
+			}
+			if marker, ok := addToCartMarker(token.Data, attrs, market); ok {
+				return decide(StreamDecision{InStock: true, Indicator: marker})
+			}
+
+			opens := token.Type == html.StartTagToken && !voidElements[token.Data]
+			if buyBoxDepth > 0 && opens {
+				buyBoxDepth++
+			} else if buyBoxIDs[attrs["id"]] && opens {
+				buyBoxDepth = 1
+			}
+			if price == 0 && opens {
+				if priceDepth > 0 {
+					priceDepth++
+				} else if priceIDs[attrs["id"]] {
+					priceDepth = 1
+				}
+				// The offscreen copy holds the full amount; bare price
+				// containers hold it directly
+				if priceDepth > 0 && !inPrice && (strings.Contains(attrs["class"], "a-offscreen") || (priceDepth == 1 && strings.HasPrefix(attrs["id"], "price"))) {
+					inPrice = true
+					priceText = ""
+				}
+			}
+			if token.Data == "button" && opens {
+				inButton = true
+				buttonText = ""
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if tag == "button" && inButton {
+				inButton = false
+				for _, label := range market.AddToCart {
+					if strings.Contains(buttonText, label) {
+						return decide(StreamDecision{InStock: true, Indicator: fmt.Sprintf("button:contains('%s')", label)})
+					}
+				}
+			}
+			if buyBoxDepth > 0 {
+				buyBoxDepth--
+			}
+			if priceDepth > 0 {
+				priceDepth--
+				if inPrice {
+					inPrice = false
+					if value, ok := ParsePrice(priceText, market); ok {
+						price = value
+						priceDepth = 0
+					}
+				}
+			}
+
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			text := string(tokenizer.Text())
+			if inButton {
+				buttonText += text
+			}
+			if inPrice {
+				priceText += text
+			}
+
+			lower := strings.ToLower(text)
+			for _, indicator := range captchaIndicators {
+				if strings.Contains(lower, indicator) {
+					return decide(StreamDecision{Captcha: true, Indicator: indicator})
+				}
+			}
+
+			for _, outOfStock := range market.OutOfStock {
+				if !strings.Contains(text, outOfStock) {
+					continue
+				}
+				if buyBoxDepth > 0 {
+					return decide(StreamDecision{Indicator: outOfStock})
+				}
+				if pageOutOfStock == "" {
+					pageOutOfStock = outOfStock
+				}
+			}
+		}
+	}
+}
+
+// addToCartMarker reports whether an element is an enabled add-to-cart control
+func addToCartMarker(tag string, attrs map[string]string, market config.Marketplace) (string, bool) {
+	_, disabled := attrs["disabled"]
+	id, class := attrs["id"], attrs["class"]
+
+	switch {
+	case id == "add-to-cart-button":
+		if disabled {
+			return "", false
+		}
+		return "#add-to-cart-button", true
+	case strings.Contains(id, "add-to-cart"):
+		return "[id*=add-to-cart]", true
+	case strings.Contains(class, "add-to-cart"):
+		return "[class*=add-to-cart]", true
+	case strings.Contains(id, "addToCart"):
+		return "[id*=addToCart]", true
+	case strings.Contains(class, "addToCart"):
+		return "[class*=addToCart]", true
+	}
+	if tag == "input" && attrs["type"] == "submit" {
+		for _, label := range market.AddToCart {
+			if strings.Contains(attrs["value"], label) {
+				return fmt.Sprintf("input[type=submit][value*='%s']", label), true
+			}
+		}
+	}
+	return "", false
+}
+
+func attrMap(attrs []html.Attribute) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Val
+	}
+	return m
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// StreamAvailability decides availability with the streaming parser and
+// stops reading the body as soon as the decision is made
+func StreamAvailability(check *Check) error {
+	ui.LogInfo("Analyzing product availability (streaming)...")
+	page := check.Page
+	market := check.Product.Market()
+	decision, err := ParseStockStatusStream(page.Stream(), market)
+	page.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse product page: %w", err)
+	}
+
+	if decision.Captcha {
+		check.Outcome.Outcome = OutcomeCaptcha
+		return ErrCaptchaDetected
+	}
+
+	switch {
+	case decision.InStock:
+		ui.LogInfo("Found add-to-cart element with selector: %s", decision.Indicator)
+	case decision.Indicator != "":
+		ui.LogInfo("Page contains '%s' text, confirming item exists but is out of stock", decision.Indicator)
+	default:
+		ui.LogInfo("No add-to-cart indicators found on the page")
+	}
+	ui.LogInfo("Decided after reading %d KB of the page", decision.BytesRead/1024)
+
+	check.Result = Result{
+		ProductID: check.Product.ID,
+		InStock:   decision.InStock,
+		Indicator: decision.Indicator,
+		CheckedAt: page.FetchedAt,
+		Outcome:   OutcomeSuccess,
+		Location:  check.Result.Location,
+		Price:     decision.Price,
+		Currency:  market.Currency,
+	}
+	check.Outcome.Outcome = OutcomeSuccess
+	return nil
+}
+
+// DetectCaptchaOnError runs captcha detection on error responses only, so a
+// block page served with a 403 or 503 is still recognized in streaming mode
+func DetectCaptchaOnError(check *Check) error {
+	if check.Page.StatusCode == 200 {
+		return nil
+	}
+	return DetectCaptcha(check)
+}
+
+// StreamPipeline is used instead of Pipeline when config.Parser is "stream"
+var StreamPipeline = []Stage{
+	{Name: "captcha", Run: DetectCaptchaOnError},
+	{Name: "status", Run: CheckResponseStatus},
+	{Name: "availability", Run: StreamAvailability},
+	{Name: "location", Run: VerifyLocation}, // The header comes before the buy box, so it was read
+	{Name: "debug", Run: SaveDebugPage},
+}
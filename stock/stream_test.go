@@ -0,0 +1,177 @@
+package stock
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"gpu-sniper/config"
+	httpClient "gpu-sniper/http"
+)
+
+var streamFixtures = []string{"amazon_in_stock.html", "amazon_out_of_stock.html", "amazon_captcha.html"}
+
+// domDecision decides a saved page with the DOM parser
+func domDecision(tb testing.TB, body []byte) (bool, float64) {
+	tb.Helper()
+	page := httpClient.NewPage(testProduct.URL, 200, nil, body)
+	inStock, _, err := parsePage(page, testProduct.Market())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	doc, err := page.Document()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	price, _ := findPrice(doc, testProduct.Market())
+	return inStock, price
+}
+
+func TestStreamMatchesDOM(t *testing.T) {
+	for _, fixture := range streamFixtures[:2] {
+		body := readFixture(t, fixture)
+		decision, err := ParseStockStatusStream(bytes.NewReader(body), testProduct.Market())
+		if err != nil {
+			t.Fatalf("%s: ParseStockStatusStream: %v", fixture, err)
+		}
+		inStock, price := domDecision(t, body)
+		if decision.InStock != inStock || decision.Price != price {
+			t.Errorf("%s: stream found in stock %t at %v, DOM found %t at %v",
+				fixture, decision.InStock, decision.Price, inStock, price)
+		}
+		if decision.BytesRead >= int64(len(body)) {
+			t.Errorf("%s: stream read %d of %d bytes, want an early decision", fixture, decision.BytesRead, len(body))
+		}
+	}
+
+	decision, err := ParseStockStatusStream(bytes.NewReader(readFixture(t, "amazon_captcha.html")), testProduct.Market())
+	if err != nil || !decision.Captcha {
+		t.Fatalf("block page: decision %+v, error %v, want a CAPTCHA", decision, err)
+	}
+}
+
+func TestStreamPipelineSnapshots(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  []byte
+		saved bool
+	}{
+		{"decided early", readFixture(t, "amazon_in_stock.html"), false},
+		{"read to the end", []byte("<html><body><p>Nothing here</p></body></html>"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			config.SaveDebugHTML = true
+			defer func() { config.SaveDebugHTML = false }()
+
+			page := streamedPage(t, tt.body)
+			check := &Check{Product: testProduct, Page: page, Outcome: CheckOutcome{Outcome: OutcomeError}}
+			if err := RunPipeline(check, StreamPipeline); err != nil {
+				t.Fatalf("RunPipeline: %v", err)
+			}
+
+			snapshots, _ := filepath.Glob("debug_*.html")
+			if saved := len(snapshots) > 0; saved != tt.saved {
+				t.Fatalf("snapshots %v, want saved %t (partial %t)", snapshots, tt.saved, page.Partial())
+			}
+		})
+	}
+}
+
+func TestStreamPipelineVerifiesLocation(t *testing.T) {
+	tests := []struct {
+		zip      string
+		location string
+	}{
+		{"10001", "10001"},
+		{"94105", ""}, // The page was rendered for New York
+	}
+	for _, tt := range tests {
+		product := testProduct
+		product.ZIP = tt.zip
+		page := streamedPage(t, readFixture(t, "amazon_in_stock.html"))
+		check := &Check{Product: product, Page: page, Result: Result{Location: tt.zip}}
+		if err := RunPipeline(check, StreamPipeline); err != nil {
+			t.Fatalf("RunPipeline: %v", err)
+		}
+		if !page.Partial() {
+			t.Fatalf("the streaming parser read the whole page")
+		}
+		if check.Result.Location != tt.location {
+			t.Errorf("ZIP %s: location = %q, want %q", tt.zip, check.Result.Location, tt.location)
+		}
+	}
+}
+
+// streamedPage serves body over HTTP and opens it for streaming
+func streamedPage(t *testing.T, body []byte) *httpClient.Page {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	session, err := httpClient.Open(httpClient.SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := session.OpenPage(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { page.Close() })
+	return page
+}
+
+func BenchmarkAvailability(b *testing.B) {
+	for _, fixture := range streamFixtures[:2] {
+		body := readFixture(b, fixture)
+		b.Run("dom/"+fixture, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				domDecision(b, body)
+			}
+		})
+		b.Run("stream/"+fixture, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseStockStatusStream(bytes.NewReader(body), testProduct.Market()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamAvailabilityTooLarge(t *testing.T) {
+	saved := config.MaxResponseBytes
+	config.MaxResponseBytes = 4096
+	defer func() { config.MaxResponseBytes = saved }()
+
+	// Without a buy box the stream only decides at the end of the page,
+	// which it never reaches
+	filler := bytes.Repeat([]byte("<p>Customer reviews</p>"), 400)
+	body := append(append([]byte("<html><body>"), filler...), "<input id=\"add-to-cart-button\"></body></html>"...)
+	check := &Check{Product: testProduct, Page: streamedPage(t, body)}
+	err := StreamAvailability(check)
+	if !errors.Is(err, httpClient.ErrBodyTooLarge) {
+		t.Fatalf("StreamAvailability = %v, result %+v, want ErrBodyTooLarge", err, check.Result)
+	}
+
+	// A decision before the limit stands
+	body = append([]byte(`<html><body><input id="add-to-cart-button">`), filler...)
+	check = &Check{Product: testProduct, Page: streamedPage(t, body)}
+	if err := StreamAvailability(check); err != nil || !check.Result.InStock {
+		t.Fatalf("StreamAvailability = %v, result %+v, want in stock", err, check.Result)
+	}
+}
@@ -0,0 +1,500 @@
+package stock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of XPath location steps
+const (
+	stepElement = iota // name or *
+	stepText           // text()
+	stepAttr           // @name, only as the last step
+	stepSelf           // .
+	stepParent         // ..
+)
+
+// xpathStep is one location step of a compiled XPath expression
+type xpathStep struct {
+	descendant bool // Preceded by //
+	kind       int
+	name       string // Element or attribute name; * matches any element
+	preds      []xpathPred
+}
+
+// xpathPred is a predicate; all of its conditions must hold
+type xpathPred struct {
+	position int  // Select the nth candidate (1-based) when > 0
+	last     bool // Select the last candidate
+	conds    []xpathCond
+}
+
+// xpathCond tests one operand of a node
+type xpathCond struct {
+	attr    string // Attribute operand; empty for text() and .
+	text    bool   // text() instead of the full string value
+	op      string // "exists", "=", "!=", "contains" or "starts-with"
+	literal string
+}
+
+// xpathExpr is a compiled XPath expression. The supported subset is
+// location paths over child and descendant steps with name tests, @attr,
+// text(), . and .., and predicates made of positions, last(), @attr,
+// comparisons with = and !=, contains() and starts-with(), joined by "and".
+type xpathExpr []xpathStep
+
+// compileXPath parses an XPath expression
+func compileXPath(expr string) (xpathExpr, error) {
+	parts, err := splitXPath(strings.TrimSpace(expr))
+	if err != nil {
+		return nil, fmt.Errorf("xpath %q: %w", expr, err)
+	}
+	var steps xpathExpr
+	for i, part := range parts {
+		step, err := parseXPathStep(part.text)
+		if err != nil {
+			return nil, fmt.Errorf("xpath %q: %w", expr, err)
+		}
+		step.descendant = part.descendant
+		if step.kind == stepAttr && i != len(parts)-1 {
+			return nil, fmt.Errorf("xpath %q: @%s must be the last step", expr, step.name)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("xpath %q is empty", expr)
+	}
+	return steps, nil
+}
+
+type xpathPart struct {
+	text       string
+	descendant bool
+}
+
+// splitXPath splits a path at the slashes outside predicates and quotes
+func splitXPath(expr string) ([]xpathPart, error) {
+	var parts []xpathPart
+	var quote byte
+	depth, start, descendant := 0, 0, false
+	flush := func(end int) error {
+		text := strings.TrimSpace(expr[start:end])
+		if text == "" {
+			if end == 0 {
+				return nil // Leading slash of an absolute path
+			}
+			return fmt.Errorf("empty step")
+		}
+		parts = append(parts, xpathPart{text: text, descendant: descendant})
+		return nil
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced ]")
+			}
+		case c == '/' && depth == 0:
+			if err := flush(i); err != nil {
+				return nil, err
+			}
+			descendant = i+1 < len(expr) && expr[i+1] == '/'
+			if descendant {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, fmt.Errorf("unterminated quote or predicate")
+	}
+	if err := flush(len(expr)); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+func parseXPathStep(text string) (xpathStep, error) {
+	head, preds := text, ""
+	if i := strings.IndexByte(text, '['); i >= 0 {
+		head, preds = strings.TrimSpace(text[:i]), text[i:]
+	}
+
+	var step xpathStep
+	switch {
+	case head == "text()":
+		step.kind = stepText
+	case head == ".":
+		step.kind = stepSelf
+	case head == "..":
+		step.kind = stepParent
+	case strings.HasPrefix(head, "@") && len(head) > 1:
+		step.kind, step.name = stepAttr, strings.ToLower(head[1:])
+	case head == "*" || isXPathName(head):
+		step.kind, step.name = stepElement, strings.ToLower(head)
+	default:
+		return step, fmt.Errorf("unsupported step %q", text)
+	}
+
+	for preds != "" {
+		end := predicateEnd(preds)
+		if !strings.HasPrefix(preds, "[") || end < 0 {
+			return step, fmt.Errorf("malformed predicate in %q", text)
+		}
+		pred, err := parseXPathPred(strings.TrimSpace(preds[1:end]))
+		if err != nil {
+			return step, err
+		}
+		step.preds = append(step.preds, pred)
+		preds = strings.TrimSpace(preds[end+1:])
+	}
+	if len(step.preds) > 0 && (step.kind == stepAttr || step.kind == stepSelf || step.kind == stepParent) {
+		return step, fmt.Errorf("predicates are not supported on %q", head)
+	}
+	return step, nil
+}
+
+// predicateEnd returns the index of the ] closing the [ that s opens, outside quotes
+func predicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseXPathPred(text string) (xpathPred, error) {
+	if n, err := strconv.Atoi(text); err == nil {
+		if n < 1 {
+			return xpathPred{}, fmt.Errorf("position %d out of range", n)
+		}
+		return xpathPred{position: n}, nil
+	}
+	if text == "last()" {
+		return xpathPred{last: true}, nil
+	}
+
+	var pred xpathPred
+	for _, part := range splitOutsideQuotes(text, " and ") {
+		cond, err := parseXPathCond(strings.TrimSpace(part))
+		if err != nil {
+			return pred, err
+		}
+		pred.conds = append(pred.conds, cond)
+	}
+	return pred, nil
+}
+
+func parseXPathCond(text string) (xpathCond, error) {
+	for _, fn := range []string{"contains", "starts-with"} {
+		if !strings.HasPrefix(text, fn+"(") || !strings.HasSuffix(text, ")") {
+			continue
+		}
+		args := splitOutsideQuotes(text[len(fn)+1:len(text)-1], ",")
+		if len(args) != 2 {
+			return xpathCond{}, fmt.Errorf("%s() takes two arguments", fn)
+		}
+		cond, err := parseXPathOperand(strings.TrimSpace(args[0]))
+		if err != nil {
+			return cond, err
+		}
+		cond.op = fn
+		cond.literal, err = parseXPathLiteral(strings.TrimSpace(args[1]))
+		return cond, err
+	}
+
+	for _, op := range []string{"!=", "="} {
+		parts := splitOutsideQuotes(text, op)
+		if len(parts) != 2 {
+			continue
+		}
+		cond, err := parseXPathOperand(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return cond, err
+		}
+		cond.op = op
+		cond.literal, err = parseXPathLiteral(strings.TrimSpace(parts[1]))
+		return cond, err
+	}
+
+	cond, err := parseXPathOperand(text)
+	if err != nil {
+		return cond, fmt.Errorf("unsupported predicate [%s]", text)
+	}
+	cond.op = "exists"
+	return cond, nil
+}
+
+func parseXPathOperand(text string) (xpathCond, error) {
+	switch {
+	case text == "text()":
+		return xpathCond{text: true}, nil
+	case text == ".":
+		return xpathCond{}, nil
+	case strings.HasPrefix(text, "@") && isXPathName(text[1:]):
+		return xpathCond{attr: strings.ToLower(text[1:])}, nil
+	}
+	return xpathCond{}, fmt.Errorf("unsupported operand %q", text)
+}
+
+func parseXPathLiteral(text string) (string, error) {
+	if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1], nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return text, nil
+	}
+	return "", fmt.Errorf("expected a quoted string, got %q", text)
+}
+
+// splitOutsideQuotes splits s at every sep that is not inside quotes
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			i += len(sep) - 1
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isXPathName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '-' || r == '_' || r == ':' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// eval returns the string values of the nodes the expression selects
+// from root, in document order
+func (x xpathExpr) eval(root *html.Node) []string {
+	nodes := []*html.Node{root}
+	for _, step := range x {
+		if step.descendant {
+			nodes = descendantsOrSelf(nodes)
+		}
+		if step.kind == stepAttr {
+			var values []string
+			for _, node := range nodes {
+				if value, ok := attrValue(node, step.name); ok {
+					values = append(values, value)
+				}
+			}
+			return values
+		}
+		nodes = step.apply(nodes)
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, nodeText(node))
+	}
+	return values
+}
+
+// apply selects the nodes one step reaches from each context node
+func (s xpathStep) apply(context []*html.Node) []*html.Node {
+	var selected []*html.Node
+	seen := make(map[*html.Node]bool)
+	add := func(node *html.Node) {
+		if node != nil && !seen[node] {
+			seen[node] = true
+			selected = append(selected, node)
+		}
+	}
+
+	for _, node := range context {
+		switch s.kind {
+		case stepSelf:
+			add(node)
+			continue
+		case stepParent:
+			add(node.Parent)
+			continue
+		}
+
+		var candidates []*html.Node
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if s.matches(child) {
+				candidates = append(candidates, child)
+			}
+		}
+		for _, pred := range s.preds {
+			candidates = pred.filter(candidates)
+		}
+		for _, candidate := range candidates {
+			add(candidate)
+		}
+	}
+	return selected
+}
+
+func (s xpathStep) matches(node *html.Node) bool {
+	if s.kind == stepText {
+		return node.Type == html.TextNode
+	}
+	return node.Type == html.ElementNode && (s.name == "*" || node.Data == s.name)
+}
+
+func (p xpathPred) filter(nodes []*html.Node) []*html.Node {
+	switch {
+	case p.position > 0:
+		if p.position > len(nodes) {
+			return nil
+		}
+		return nodes[p.position-1 : p.position]
+	case p.last:
+		if len(nodes) == 0 {
+			return nil
+		}
+		return nodes[len(nodes)-1:]
+	}
+
+	var kept []*html.Node
+	for _, node := range nodes {
+		if p.holds(node) {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
+
+func (p xpathPred) holds(node *html.Node) bool {
+	for _, cond := range p.conds {
+		if !cond.holds(node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c xpathCond) holds(node *html.Node) bool {
+	var value string
+	switch {
+	case c.attr != "":
+		v, ok := attrValue(node, c.attr)
+		if !ok {
+			return false
+		}
+		value = v
+	case c.text:
+		value = ownText(node)
+	default:
+		value = nodeText(node)
+	}
+
+	switch c.op {
+	case "=":
+		return value == c.literal
+	case "!=":
+		return value != c.literal
+	case "contains":
+		return strings.Contains(value, c.literal)
+	case "starts-with":
+		return strings.HasPrefix(value, c.literal)
+	}
+	// "exists": the attribute is present, or the text is not empty
+	return c.attr != "" || value != ""
+}
+
+// descendantsOrSelf returns the nodes and all of their descendants
+func descendantsOrSelf(nodes []*html.Node) []*html.Node {
+	var all []*html.Node
+	seen := make(map[*html.Node]bool)
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if seen[node] {
+			return
+		}
+		seen[node] = true
+		all = append(all, node)
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+	return all
+}
+
+func attrValue(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// ownText concatenates the text children of a node
+func ownText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var b strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			b.WriteString(child.Data)
+		}
+	}
+	return b.String()
+}
+
+// nodeText concatenates all text inside a node
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return b.String()
+}
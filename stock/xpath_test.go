@@ -0,0 +1,119 @@
+package stock
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const xpathPage = `<html><body>
+<div id="buybox" class="box main">
+  <span class="price" data-currency="USD">$1,999.99</span>
+  <ul>
+    <li>first</li>
+    <li class="offer">second <b>bold</b></li>
+    <li data-seller="it's us">third</li>
+  </ul>
+  <button id="add" disabled>Add to Cart</button>
+</div>
+<div class="box">
+  <ul><li>other first</li><li>other last</li></ul>
+  <a href="/cart?a=1&amp;b=2" title='say "hi"'>Cart</a>
+</div>
+</body></html>`
+
+func TestXPath(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		// Child and descendant steps
+		{"/html/body/div/span", []string{"$1,999.99"}},
+		{"//span", []string{"$1,999.99"}},
+		{"//div//b", []string{"bold"}},
+		{"//ul/li/b", []string{"bold"}},
+		{"/html//button", []string{"Add to Cart"}},
+		{"//nav", nil},
+
+		// Attributes
+		{"//span/@data-currency", []string{"USD"}},
+		{"//a/@href", []string{"/cart?a=1&b=2"}},
+		{"//div/@class", []string{"box main", "box"}},
+		{"//button/@missing", nil},
+
+		// Positions are counted per parent, as in XPath
+		{"//li[1]", []string{"first", "other first"}},
+		{"//li[last()]", []string{"third", "other last"}},
+		{"//div[2]/ul/li[2]", []string{"other last"}},
+		{"//li[4]", nil},
+
+		// Attribute predicates
+		{"//div[@id='buybox']/span", []string{"$1,999.99"}},
+		{`//div[@id="buybox"]/span`, []string{"$1,999.99"}},
+		{"//div[@class!='box main']//a", []string{"Cart"}},
+		{"//div[@id!='buybox']//a", nil}, // Like XPath, a missing attribute equals nothing
+		{"//button[@disabled]", []string{"Add to Cart"}},
+		{"//li[@class]", []string{"second bold"}},
+		{"//div[contains(@class, 'main')]/span", []string{"$1,999.99"}},
+		{"//div[starts-with(@class, 'box') and @id]/button", []string{"Add to Cart"}},
+
+		// Text predicates and steps
+		{"//li[text()='first']", []string{"first"}},
+		{"//li[contains(., 'bold')]", []string{"second bold"}},
+		{"//li[contains(text(), 'bold')]", nil}, // The bold text is not the li's own
+		{"//li[@class]/text()", []string{"second"}},
+		{"//b/..", []string{"second bold"}},
+		{"//b/.", []string{"bold"}},
+		{"//button[. = 'Add to Cart']/@id", []string{"add"}},
+
+		// Quoting: the other quote, brackets, slashes and "and" inside literals
+		{`//li[@data-seller="it's us"]`, []string{"third"}},
+		{`//a[@title='say "hi"']`, []string{"Cart"}},
+		{"//a[@href='/cart?a=1&b=2']", []string{"Cart"}},
+		{"//a[@title!='x and y']", []string{"Cart"}},
+		{"//span[@data-currency='U[S]D']", nil},
+	}
+	for _, tt := range tests {
+		x, err := compileXPath(tt.expr)
+		if err != nil {
+			t.Errorf("compileXPath(%q): %v", tt.expr, err)
+			continue
+		}
+		got := x.eval(root)
+		for i := range got {
+			got[i] = strings.Join(strings.Fields(got[i]), " ")
+		}
+		if len(got) == 0 {
+			got = nil
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"//",
+		"//div[",
+		"//div]",
+		"//div[@id='x]",
+		"//@id/span",         // Attributes end a path
+		"//li[0]",            // Positions start at 1
+		"//li[position()<3]", // Not in the subset
+		"//span[@a or @b]",
+		"//span/@id[1]",
+		"//a|//b",
+	} {
+		if _, err := compileXPath(expr); err == nil {
+			t.Errorf("compileXPath(%q) succeeded", expr)
+		}
+	}
+}
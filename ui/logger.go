@@ -8,8 +8,14 @@ import (
 	"gpu-sniper/config"
 )
 
+// Quiet drops info and success messages, e.g. while saved pages are replayed
+var Quiet bool
+
 // LogInfo logs an informational message with timestamp
 func LogInfo(format string, args ...interface{}) {
+	if Quiet {
+		return
+	}
 	timestamp := time.Now().Format("15:04:05")
 	timeStr := config.TimeColor.Sprintf("[%s]", timestamp)
 	prefix := config.InfoColor.Sprint("INFO  ")
@@ -18,6 +24,9 @@ func LogInfo(format string, args ...interface{}) {
 
 // LogSuccess logs a success message with timestamp
 func LogSuccess(format string, args ...interface{}) {
+	if Quiet {
+		return
+	}
 	timestamp := time.Now().Format("15:04:05")
 	timeStr := config.TimeColor.Sprintf("[%s]", timestamp)
 	prefix := config.SuccessColor.Sprint("OK    ")
@@ -43,8 +52,22 @@ func LogWarning(format string, args ...interface{}) {
 // PrintHeader prints the application header with styling
 func PrintHeader() {
 	fmt.Println()
-	config.HeaderColor.Printf("🔍 GPU SNIPER - Monitoring for %s\n", config.TargetGPU)
-	config.HeaderColor.Printf("🔗 Retailer URL: %s\n", config.RetailerURL)
+	if len(config.Products) == 1 {
+		config.HeaderColor.Printf("🔍 GPU SNIPER - Monitoring for %s\n", config.Products[0].DisplayName())
+		config.HeaderColor.Printf("🔗 Retailer URL: %s\n", config.Products[0].URL)
+		if config.Products[0].ZIP != "" {
+			config.HeaderColor.Printf("📍 Delivery location: %s\n", config.Products[0].ZIP)
+		}
+	} else {
+		config.HeaderColor.Printf("🔍 GPU SNIPER - Monitoring %d products\n", len(config.Products))
+		for _, product := range config.Products {
+			if product.ZIP != "" {
+				config.HeaderColor.Printf("🔗 %s (deliver to %s): %s\n", product.DisplayName(), product.ZIP, product.URL)
+			} else {
+				config.HeaderColor.Printf("🔗 %s: %s\n", product.DisplayName(), product.URL)
+			}
+		}
+	}
 	config.HeaderColor.Printf("💻 By: nick-neely (github)\n")
 	config.HeaderColor.Printf("⏱️  Default check interval: %s (adjusts automatically)\n", config.DefaultPollingInterval)
 	config.HeaderColor.Printf("🛡️  Anti-bot measures: Random user agents, jittered timing, related page visits\n")
@@ -56,9 +79,51 @@ func PrintStatusUpdate() {
 	if config.CheckCount > 0 {
 		timeSinceLastCheck := time.Since(config.LastCheckTime)
 		fmt.Printf("\r\033[K") // Clear the current line
-		fmt.Printf("[STATUS] Checks: %d | Current interval: %v | Last check: %v ago\n",
+		fmt.Printf("[STATUS] Checks: %d | Last check: %v ago\n",
 			config.CheckCount, 
-			config.PollingInterval,
 			timeSinceLastCheck.Round(time.Second))
 	}
 }
+
+// SessionSummary totals what happened during a run
+type SessionSummary struct {
+	Started   time.Time      // When monitoring started
+	Checks    int            // Stock checks that ran
+	InStock   int            // Checks that found the product in stock
+	Alerts    int            // Purchase alerts raised
+	Failures  int            // Checks that ended in an error
+	Captchas  int            // Checks blocked by a CAPTCHA
+	Skipped   int            // Checks skipped while cooling down
+	Cooldowns []HostCooldown // Hosts still cooling down when the run ended
+	Problems  []string       // Shutdown steps that did not complete
+}
+
+// HostCooldown is a host whose checks are paused after a CAPTCHA
+type HostCooldown struct {
+	Host    string
+	Until   time.Time // When checks resume
+	Strikes int       // Consecutive checks that hit a CAPTCHA
+}
+
+// PrintSessionSummary prints the totals for the run that is ending
+func PrintSessionSummary(summary SessionSummary) {
+	fmt.Println(strings.Repeat("═", 50))
+	config.HeaderColor.Printf("📊 Session summary (%v)\n", time.Since(summary.Started).Round(time.Second))
+	fmt.Printf("   Checks: %d | In stock: %d | Alerts: %d\n", summary.Checks, summary.InStock, summary.Alerts)
+	fmt.Printf("   Failures: %d | CAPTCHAs: %d | Skipped: %d\n", summary.Failures, summary.Captchas, summary.Skipped)
+	for _, c := range summary.Cooldowns {
+		config.WarningColor.Printf("   Cooling down: %s until %s (%d CAPTCHA(s) in a row)\n", c.Host, c.Until.Format("03:04 PM"), c.Strikes)
+	}
+	for _, problem := range summary.Problems {
+		config.ErrorColor.Printf("   ✗ %s\n", problem)
+	}
+	fmt.Println(strings.Repeat("═", 50))
+}
+
+// FormatPrice formats an amount with its ISO 4217 currency code
+func FormatPrice(amount float64, currency string) string {
+	if currency == "JPY" {
+		return fmt.Sprintf("%.0f %s", amount, currency)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
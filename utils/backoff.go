@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// JitterMode selects how randomness is applied to exponential backoff delays
+type JitterMode int
+
+const (
+	// JitterNone uses the exact exponential delay
+	JitterNone JitterMode = iota
+	// JitterFull picks a random delay between zero and the exponential delay
+	JitterFull
+	// JitterDecorrelated picks a random delay between the initial delay and
+	// three times the previous delay, capped at the maximum
+	JitterDecorrelated
+)
+
+// BackoffStrategy decides how long to wait before the next retry.
+// retry is the 1-based number of the retry about to happen and prev is the
+// delay used before the previous retry (zero before the first one).
+// Returning false means the operation should not be retried again.
+type BackoffStrategy interface {
+	NextDelay(retry int, prev time.Duration) (time.Duration, bool)
+}
+
+// ExponentialBackoff grows the delay by Factor after every retry
+type ExponentialBackoff struct {
+	MaxRetries int           // Maximum number of retry attempts
+	Initial    time.Duration // Delay before the first retry
+	Max        time.Duration // Upper bound for any delay
+	Factor     float64       // Multiplier applied after each retry
+	Jitter     JitterMode    // Randomization applied to each delay
+}
+
+// NextDelay implements BackoffStrategy
+func (b ExponentialBackoff) NextDelay(retry int, prev time.Duration) (time.Duration, bool) {
+	if retry > b.MaxRetries {
+		return 0, false
+	}
+
+	factor := b.Factor
+	if factor < 1 {
+		factor = 1
+	}
+
+	if b.Jitter == JitterDecorrelated {
+		if prev <= 0 {
+			prev = b.Initial
+		}
+		upper := prev * 3
+		if b.Max > 0 && upper > b.Max {
+			upper = b.Max
+		}
+		return randomBetween(b.Initial, upper), true
+	}
+
+	delay := b.Initial
+	for i := 1; i < retry; i++ {
+		delay = time.Duration(float64(delay) * factor)
+		if b.Max > 0 && delay >= b.Max {
+			delay = b.Max
+			break
+		}
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+
+	if b.Jitter == JitterFull {
+		return randomBetween(0, delay), true
+	}
+	return delay, true
+}
+
+// FixedBackoff waits the same delay before every retry
+type FixedBackoff struct {
+	MaxRetries int
+	Delay      time.Duration
+}
+
+// NextDelay implements BackoffStrategy
+func (b FixedBackoff) NextDelay(retry int, prev time.Duration) (time.Duration, bool) {
+	if retry > b.MaxRetries {
+		return 0, false
+	}
+	return b.Delay, true
+}
+
+// NoRetry never retries; the first error is returned as is
+type NoRetry struct{}
+
+// NextDelay implements BackoffStrategy
+func (NoRetry) NextDelay(retry int, prev time.Duration) (time.Duration, bool) {
+	return 0, false
+}
+
+// randomBetween returns a random duration in [lo, hi]
+func randomBetween(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)+1))
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffNoJitter(t *testing.T) {
+	b := ExponentialBackoff{MaxRetries: 6, Initial: time.Second, Max: 10 * time.Second, Factor: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	var prev time.Duration
+	for i, w := range want {
+		delay, retry := b.NextDelay(i+1, prev)
+		if delay != w || !retry {
+			t.Errorf("retry %d: NextDelay = %v, %t, want %v, true", i+1, delay, retry, w)
+		}
+		prev = delay
+	}
+	if _, retry := b.NextDelay(7, prev); retry {
+		t.Error("retried past MaxRetries")
+	}
+
+	// A factor below 1 keeps the delay constant
+	flat := ExponentialBackoff{MaxRetries: 3, Initial: time.Second, Factor: 0.5}
+	if delay, _ := flat.NextDelay(3, time.Second); delay != time.Second {
+		t.Errorf("factor 0.5: NextDelay = %v, want 1s", delay)
+	}
+}
+
+func TestExponentialBackoffFullJitter(t *testing.T) {
+	b := ExponentialBackoff{MaxRetries: 10, Initial: time.Second, Max: 5 * time.Second, Factor: 2, Jitter: JitterFull}
+	for retry := 1; retry <= 10; retry++ {
+		upper := min(time.Second<<(retry-1), 5*time.Second)
+		for range 100 {
+			delay, ok := b.NextDelay(retry, 0)
+			if !ok || delay < 0 || delay > upper {
+				t.Fatalf("retry %d: NextDelay = %v, %t, want within [0, %v]", retry, delay, ok, upper)
+			}
+		}
+	}
+}
+
+func TestExponentialBackoffDecorrelatedJitter(t *testing.T) {
+	b := ExponentialBackoff{MaxRetries: 50, Initial: time.Second, Max: 20 * time.Second, Jitter: JitterDecorrelated}
+	var prev time.Duration
+	for retry := 1; retry <= 50; retry++ {
+		upper := 3 * prev
+		if prev == 0 {
+			upper = 3 * time.Second
+		}
+		upper = min(upper, 20*time.Second)
+		delay, ok := b.NextDelay(retry, prev)
+		if !ok || delay < time.Second || delay > upper {
+			t.Fatalf("retry %d after %v: NextDelay = %v, %t, want within [1s, %v]", retry, prev, delay, ok, upper)
+		}
+		prev = delay
+	}
+
+	// The cap applies even when the previous delay was larger
+	if delay, _ := b.NextDelay(1, time.Hour); delay > 20*time.Second {
+		t.Errorf("NextDelay after 1h = %v, want at most 20s", delay)
+	}
+}
+
+func TestFixedBackoffAndNoRetry(t *testing.T) {
+	b := FixedBackoff{MaxRetries: 2, Delay: 3 * time.Second}
+	for retry := 1; retry <= 2; retry++ {
+		if delay, ok := b.NextDelay(retry, time.Hour); delay != 3*time.Second || !ok {
+			t.Errorf("retry %d: NextDelay = %v, %t, want 3s, true", retry, delay, ok)
+		}
+	}
+	if _, ok := b.NextDelay(3, 3*time.Second); ok {
+		t.Error("FixedBackoff retried past MaxRetries")
+	}
+	if delay, ok := (NoRetry{}).NextDelay(1, 0); delay != 0 || ok {
+		t.Errorf("NoRetry.NextDelay = %v, %t, want 0, false", delay, ok)
+	}
+}
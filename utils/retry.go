@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,60 +10,196 @@ import (
 	"gpu-sniper/ui"
 )
 
-// RetryOperation executes the provided function with retry logic
-func RetryOperation(operation func() error, retryConfig config.RetryConfig) error {
+// RetryRule maps a class of errors to the backoff strategy used for it
+type RetryRule struct {
+	Name     string           // Label reported to the attempt callback
+	Match    func(error) bool // Reports whether the rule applies to an error
+	Strategy BackoffStrategy  // How to back off for matching errors
+}
+
+// AttemptInfo describes a finished attempt and what happens next
+type AttemptInfo struct {
+	Attempt   int           // 1-based attempt number
+	Err       error         // Error returned by the attempt, nil on success
+	Rule      string        // Name of the matching rule ("default" if none matched)
+	Delay     time.Duration // Wait before the next attempt when WillRetry is set
+	WillRetry bool          // Whether another attempt will be made
+}
+
+// RetryPolicy selects a backoff strategy per error class
+type RetryPolicy struct {
+	Rules         []RetryRule       // Checked in order; first match wins
+	Default       BackoffStrategy   // Used when no rule matches; nil means no retry
+	MaxRetryAfter time.Duration     // Give up if the server asks to wait longer than this (0 = no limit)
+	OnAttempt     func(AttemptInfo) // Optional callback invoked after every attempt
+}
+
+// RetryAfterError wraps an error with a server-requested delay (e.g. the
+// HTTP Retry-After header). Retry waits at least After before trying again.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// ForError returns a rule matching errors that wrap target
+func ForError(name string, target error, strategy BackoffStrategy) RetryRule {
+	return RetryRule{
+		Name:     name,
+		Match:    func(err error) bool { return errors.Is(err, target) },
+		Strategy: strategy,
+	}
+}
+
+// Retry runs operation until it succeeds, the policy gives up, or ctx is done.
+// Waiting between attempts is interrupted as soon as ctx is cancelled.
+func Retry(ctx context.Context, operation func(ctx context.Context) error, policy RetryPolicy) error {
 	var err error
-	backoff := retryConfig.InitialBackoff
-
-	for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
-		// First attempt or retry
-		if attempt > 0 {
-			ui.LogWarning("Retry attempt %d of %d after error: %v", 
-				attempt, retryConfig.MaxRetries, err)
-			
-			// Special handling for specific error types
-			if err == ErrCaptchaDetected && attempt == 1 {
-				ui.LogWarning("CAPTCHA detected, extended cooling period in effect (%v)", backoff)
-			}
-			
-			time.Sleep(backoff)
-			
-			// Increase backoff for next potential retry
-			backoff = time.Duration(float64(backoff) * retryConfig.BackoffFactor)
-			if backoff > retryConfig.MaxBackoff {
-				backoff = retryConfig.MaxBackoff
-			}
+	var prevDelay time.Duration
+
+	for attempt := 1; ; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return abortError(ctxErr, err)
 		}
 
-		// Execute the operation
-		err = operation()
-		
-		// If successful or specific non-retryable errors, return immediately
+		err = operation(ctx)
+
+		info := AttemptInfo{Attempt: attempt, Err: err}
 		if err == nil {
+			policy.report(info)
 			return nil
 		}
-		
-		// Check if we've hit max retries
-		if attempt == retryConfig.MaxRetries {
-			return fmt.Errorf("operation failed after %d attempts: %w", retryConfig.MaxRetries+1, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			policy.report(info)
+			return abortError(ctxErr, err)
 		}
-		
-		// Check if this error is retryable (if specific errors were provided)
-		if len(retryConfig.RetryableErrors) > 0 {
-			retryable := false
-			for _, retryableErr := range retryConfig.RetryableErrors {
-				if err.Error() == retryableErr.Error() {
-					retryable = true
-					break
-				}
+
+		strategy, rule := policy.strategyFor(err)
+		info.Rule = rule
+		delay, retry := strategy.NextDelay(attempt, prevDelay)
+
+		// Honor server-requested delays
+		var retryAfter *RetryAfterError
+		if retry && errors.As(err, &retryAfter) {
+			if policy.MaxRetryAfter > 0 && retryAfter.After > policy.MaxRetryAfter {
+				retry = false
+			} else if retryAfter.After > delay {
+				delay = retryAfter.After
 			}
-			if !retryable {
-				return err // Don't retry non-retryable errors
+		}
+
+		info.Delay = delay
+		info.WillRetry = retry
+		policy.report(info)
+
+		if !retry {
+			if attempt == 1 {
+				return err
 			}
+			return fmt.Errorf("operation failed after %d attempts: %w", attempt, err)
 		}
+
+		if waitErr := Sleep(ctx, delay); waitErr != nil {
+			return abortError(waitErr, err)
+		}
+		prevDelay = delay
 	}
+}
 
-	return fmt.Errorf("operation failed after %d attempts: %w", retryConfig.MaxRetries+1, err)
+// RetryOperation executes the provided function with retry logic, giving up
+// as soon as ctx is cancelled
+func RetryOperation(ctx context.Context, operation func(ctx context.Context) error, retryConfig config.RetryConfig) error {
+	return Retry(ctx, operation, PolicyFromConfig(retryConfig))
+}
+
+// PolicyFromConfig builds an exponential backoff policy from a RetryConfig
+func PolicyFromConfig(retryConfig config.RetryConfig) RetryPolicy {
+	backoff := ExponentialBackoff{
+		MaxRetries: retryConfig.MaxRetries,
+		Initial:    retryConfig.InitialBackoff,
+		Max:        retryConfig.MaxBackoff,
+		Factor:     retryConfig.BackoffFactor,
+	}
+
+	policy := RetryPolicy{
+		Default:   backoff,
+		OnAttempt: LogAttempt,
+	}
+
+	// Only retry the listed errors if specific ones were provided
+	if len(retryConfig.RetryableErrors) > 0 {
+		policy.Default = NoRetry{}
+		for _, retryableErr := range retryConfig.RetryableErrors {
+			target := retryableErr
+			policy.Rules = append(policy.Rules, RetryRule{
+				Name: target.Error(),
+				Match: func(err error) bool {
+					return errors.Is(err, target) || err.Error() == target.Error()
+				},
+				Strategy: backoff,
+			})
+		}
+	}
+
+	return policy
+}
+
+// LogAttempt is an attempt callback that logs upcoming retries
+func LogAttempt(info AttemptInfo) {
+	if info.Err != nil && info.WillRetry {
+		ui.LogWarning("Attempt %d failed: %v (retrying in %v)",
+			info.Attempt, info.Err, info.Delay.Round(time.Millisecond))
+	}
+}
+
+func (p RetryPolicy) strategyFor(err error) (BackoffStrategy, string) {
+	for _, rule := range p.Rules {
+		if rule.Match != nil && rule.Match(err) {
+			if rule.Strategy == nil {
+				return NoRetry{}, rule.Name
+			}
+			return rule.Strategy, rule.Name
+		}
+	}
+	if p.Default == nil {
+		return NoRetry{}, "default"
+	}
+	return p.Default, "default"
+}
+
+func (p RetryPolicy) report(info AttemptInfo) {
+	if p.OnAttempt != nil {
+		p.OnAttempt(info)
+	}
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func abortError(ctxErr, lastErr error) error {
+	if lastErr == nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w (last error: %v)", ctxErr, lastErr)
 }
 
 // Define common errors for use with retry logic
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	errBlocked = errors.New("blocked")
+	errTimeout = errors.New("timeout")
+)
+
+// failing returns an operation that fails with errs in turn, then succeeds
+func failing(errs ...error) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryRuleOrder(t *testing.T) {
+	policy := RetryPolicy{
+		Rules: []RetryRule{
+			ForError("blocked", errBlocked, NoRetry{}),
+			ForError("timeout", errTimeout, FixedBackoff{MaxRetries: 1}),
+		},
+		Default: FixedBackoff{MaxRetries: 2},
+	}
+	tests := []struct {
+		err      error
+		rule     string
+		attempts int
+	}{
+		{fmt.Errorf("fetch: %w", errTimeout), "timeout", 2},
+		{errors.Join(errTimeout, errBlocked), "blocked", 1}, // Both match, the first rule wins
+		{errors.New("reset"), "default", 3},
+	}
+	for _, tt := range tests {
+		var rules []string
+		policy.OnAttempt = func(info AttemptInfo) { rules = append(rules, info.Rule) }
+		op, calls := failing(tt.err, tt.err, tt.err, tt.err)
+		err := Retry(context.Background(), op, policy)
+		if !errors.Is(err, tt.err) || *calls != tt.attempts {
+			t.Errorf("%v: Retry = %v after %d attempt(s), want %d", tt.err, err, *calls, tt.attempts)
+		}
+		if rules[0] != tt.rule {
+			t.Errorf("%v: rule %s, want %s", tt.err, rules[0], tt.rule)
+		}
+	}
+
+	// Without a default, unmatched errors are not retried
+	op, calls := failing(errors.New("reset"))
+	if err := Retry(context.Background(), op, RetryPolicy{}); err == nil || *calls != 1 {
+		t.Errorf("no default: Retry = %v after %d attempt(s), want the error after 1", err, *calls)
+	}
+}
+
+func TestRetryAttemptCallback(t *testing.T) {
+	var attempts []AttemptInfo
+	policy := RetryPolicy{
+		Default:   FixedBackoff{MaxRetries: 3, Delay: time.Millisecond},
+		OnAttempt: func(info AttemptInfo) { attempts = append(attempts, info) },
+	}
+	op, _ := failing(errTimeout, errBlocked)
+	if err := Retry(context.Background(), op, policy); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	want := []AttemptInfo{
+		{Attempt: 1, Err: errTimeout, Rule: "default", Delay: time.Millisecond, WillRetry: true},
+		{Attempt: 2, Err: errBlocked, Rule: "default", Delay: time.Millisecond, WillRetry: true},
+		{Attempt: 3},
+	}
+	if len(attempts) != len(want) {
+		t.Fatalf("callback saw %d attempt(s), want %d", len(attempts), len(want))
+	}
+	for i := range want {
+		if attempts[i] != want[i] {
+			t.Errorf("attempt %d = %+v, want %+v", i+1, attempts[i], want[i])
+		}
+	}
+
+	// Giving up reports the last attempt too
+	attempts = nil
+	op, _ = failing(errTimeout, errTimeout, errTimeout, errTimeout)
+	err := Retry(context.Background(), op, policy)
+	if err == nil || !strings.Contains(err.Error(), "operation failed after 4 attempts") {
+		t.Errorf("Retry = %v, want a failure after 4 attempts", err)
+	}
+	if len(attempts) != 4 || attempts[3].WillRetry {
+		t.Errorf("callback saw %+v, want 4 attempts, the last without a retry", attempts)
+	}
+}
+
+func TestRetryAfterError(t *testing.T) {
+	var delays []time.Duration
+	policy := RetryPolicy{
+		Default:       FixedBackoff{MaxRetries: 1, Delay: time.Millisecond},
+		MaxRetryAfter: time.Second,
+		OnAttempt:     func(info AttemptInfo) { delays = append(delays, info.Delay) },
+	}
+
+	// The server asks for longer than the backoff: wait as asked
+	op, calls := failing(&RetryAfterError{Err: errBlocked, After: 30 * time.Millisecond})
+	start := time.Now()
+	if err := Retry(context.Background(), op, policy); err != nil || *calls != 2 {
+		t.Fatalf("Retry = %v after %d attempt(s), want success after 2", err, *calls)
+	}
+	if elapsed := time.Since(start); delays[0] != 30*time.Millisecond || elapsed < 30*time.Millisecond {
+		t.Errorf("waited %v (delay %v), want 30ms", elapsed, delays[0])
+	}
+
+	// Longer than MaxRetryAfter: give up at once
+	delays = nil
+	op, calls = failing(&RetryAfterError{Err: errBlocked, After: time.Hour})
+	err := Retry(context.Background(), op, policy)
+	if !errors.Is(err, errBlocked) || *calls != 1 {
+		t.Errorf("Retry = %v after %d attempt(s), want the error after 1", err, *calls)
+	}
+	if err == nil || !strings.Contains(err.Error(), "retry after 1h0m0s") {
+		t.Errorf("Retry = %v, want the requested delay in the error", err)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	op, calls := failing(errTimeout, errTimeout)
+	start := time.Now()
+	err := Retry(ctx, op, RetryPolicy{Default: FixedBackoff{MaxRetries: 5, Delay: time.Hour}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retry returned after %v, want promptly after the cancellation", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("Retry = %v after %d attempt(s), want context.Canceled after 1", err, *calls)
+	}
+	if err == nil || !strings.Contains(err.Error(), "last error: timeout") {
+		t.Errorf("Retry = %v, want the last error mentioned", err)
+	}
+}